@@ -0,0 +1,308 @@
+// Package accounts links sign-in methods (identities) to users, so someone
+// who signs up with a password and later uses Google keeps one account.
+package accounts
+
+import (
+	"Auth/database"
+	"Auth/identity"
+	"Auth/models"
+	"Auth/tenancy"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdentityLinked   = errors.New("sign-in method is linked to another account")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrLastIdentity     = errors.New("cannot unlink the only sign-in method")
+	ErrEmailTaken       = errors.New("an account with this email already exists")
+)
+
+// ProviderName maps a provider ID (google.com) to the short name stored in
+// users.provider and user_identities.provider
+func ProviderName(providerID string) string {
+	switch providerID {
+	case "google.com":
+		return "google"
+	case "facebook.com":
+		return "facebook"
+	case "apple.com":
+		return "apple"
+	case "":
+		return "password"
+	default:
+		return providerID
+	}
+}
+
+// providerID is the inverse of ProviderName
+func providerID(name string) string {
+	switch name {
+	case "google", "facebook", "apple":
+		return name + ".com"
+	default:
+		return name
+	}
+}
+
+// SignInProvider names the method a token was obtained with. Tokens that do
+// not tell (custom tokens) fall back to the account's first method.
+func SignInProvider(token *identity.Token, record *identity.UserRecord) string {
+	if token.SignInProvider != "" && token.SignInProvider != "custom" {
+		return ProviderName(token.SignInProvider)
+	}
+	return methodsOf(record)[0].Provider
+}
+
+// methodsOf lists the sign-in methods of an identity-provider account as
+// unsaved identities
+func methodsOf(record *identity.UserRecord) []models.UserIdentity {
+	if len(record.ProviderUserInfo) == 0 {
+		// accounts without linked methods sign in with email and password
+		return []models.UserIdentity{{
+			Provider:    "password",
+			ProviderUID: record.UID,
+			FirebaseUID: record.UID,
+			Email:       record.Email,
+		}}
+	}
+
+	methods := make([]models.UserIdentity, 0, len(record.ProviderUserInfo))
+	for _, info := range record.ProviderUserInfo {
+		methods = append(methods, models.UserIdentity{
+			Provider:    ProviderName(info.ProviderID),
+			ProviderUID: info.UID,
+			FirebaseUID: record.UID,
+			Email:       info.Email,
+		})
+	}
+	return methods
+}
+
+// LinkedUserID returns the user a separately linked identity-provider
+// account belongs to, or gorm.ErrRecordNotFound
+func LinkedUserID(ctx context.Context, firebaseUID string) (uint, error) {
+	var linked models.UserIdentity
+	if err := database.DB.WithContext(ctx).
+		Where("firebase_uid = ?", firebaseUID).
+		First(&linked).Error; err != nil {
+		return 0, err
+	}
+	return linked.UserID, nil
+}
+
+// Resolve finds the user a verified sign-in belongs to: by the account's
+// UID, by an account linked earlier, or by its email. A match by email is
+// linked only when both sides verified the address; otherwise
+// ErrEmailTaken keeps the account from being taken over. Returns
+// gorm.ErrRecordNotFound for a new user.
+func Resolve(ctx context.Context, record *identity.UserRecord) (*models.User, error) {
+	db := database.DB.WithContext(ctx)
+
+	var user models.User
+	err := db.Preload("Roles").Where("firebase_uid = ?", record.UID).First(&user).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		if err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+
+	userID, err := LinkedUserID(ctx, record.UID)
+	switch {
+	case err == nil:
+		if err := db.Preload("Roles").First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// linked to a user of another board
+				return nil, ErrIdentityLinked
+			}
+			return nil, err
+		}
+		return &user, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(record.Email))
+	if email == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	err = db.Preload("Roles").Where("LOWER(email) = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// emails are unique across boards
+		var taken int64
+		if err := database.DB.WithContext(tenancy.System(ctx)).Model(&models.User{}).
+			Where("LOWER(email) = ?", email).
+			Count(&taken).Error; err != nil {
+			return nil, err
+		}
+		if taken > 0 {
+			return nil, ErrEmailTaken
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !record.EmailVerified || user.EmailVerifiedAt == nil {
+		return nil, ErrEmailTaken
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return link(tx, user.ID, record)
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("🔗 Account %s linked to user %d by verified email", record.UID, user.ID)
+	return &user, nil
+}
+
+// link records the methods of an identity-provider account for the user
+// inside tx, refusing accounts and methods that belong to someone else
+func link(tx *gorm.DB, userID uint, record *identity.UserRecord) error {
+	var others int64
+	if err := tx.Model(&models.UserIdentity{}).
+		Where("firebase_uid = ? AND user_id <> ?", record.UID, userID).
+		Count(&others).Error; err != nil {
+		return err
+	}
+	if others > 0 {
+		return ErrIdentityLinked
+	}
+
+	now := time.Now()
+	for _, method := range methodsOf(record) {
+		var existing models.UserIdentity
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_uid = ?", method.Provider, method.ProviderUID).
+			First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			method.UserID = userID
+			method.LinkedAt = now
+			if err := tx.Create(&method).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case existing.UserID != userID:
+			return ErrIdentityLinked
+		default:
+			if err := tx.Model(&existing).Updates(map[string]interface{}{
+				"firebase_uid": method.FirebaseUID,
+				"email":        method.Email,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sync brings the user's identities in line with an identity-provider
+// account after a sign-in: new methods are recorded and methods unlinked at
+// the provider are removed
+func Sync(ctx context.Context, userID uint, record *identity.UserRecord) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := link(tx, userID, record); err != nil {
+			return err
+		}
+
+		var identities []models.UserIdentity
+		if err := tx.Where("user_id = ? AND firebase_uid = ?", userID, record.UID).
+			Find(&identities).Error; err != nil {
+			return err
+		}
+		methods := methodsOf(record)
+		for _, linked := range identities {
+			if !slices.ContainsFunc(methods, func(method models.UserIdentity) bool {
+				return method.Provider == linked.Provider && method.ProviderUID == linked.ProviderUID
+			}) {
+				if err := tx.Delete(&linked).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Link attaches another identity-provider account the user just signed in
+// to, so its methods sign in to this user from now on
+func Link(ctx context.Context, user *models.User, record *identity.UserRecord) error {
+	if record.UID != user.FirebaseUID {
+		// the account may already be a user of its own, on any board
+		var owners int64
+		if err := database.DB.WithContext(tenancy.System(ctx)).Model(&models.User{}).
+			Where("firebase_uid = ?", record.UID).
+			Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners > 0 {
+			return ErrIdentityLinked
+		}
+	}
+	return Sync(ctx, user.ID, record)
+}
+
+// Unlink removes a sign-in method of the user; methods of the user's own
+// account are unlinked at the identity provider as well. The last method
+// cannot be removed.
+func Unlink(ctx context.Context, user *models.User, identityID uint) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var identities []models.UserIdentity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", user.ID).
+			Find(&identities).Error; err != nil {
+			return err
+		}
+		i := slices.IndexFunc(identities, func(linked models.UserIdentity) bool {
+			return linked.ID == identityID
+		})
+		if i < 0 {
+			return ErrIdentityNotFound
+		}
+		if len(identities) == 1 {
+			return ErrLastIdentity
+		}
+
+		target := identities[i]
+		if err := tx.Delete(&target).Error; err != nil {
+			return err
+		}
+		if target.FirebaseUID != user.FirebaseUID {
+			return nil
+		}
+		_, err := identity.Get().UpdateUser(ctx, user.FirebaseUID, identity.UserToUpdate{
+			ProvidersToDelete: []string{providerID(target.Provider)},
+		})
+		return err
+	})
+}
+
+// Forget removes the identities of a deleted user and returns the other
+// identity-provider accounts that were linked, for the caller to delete
+func Forget(ctx context.Context, user *models.User) ([]string, error) {
+	var identities []models.UserIdentity
+	if err := database.DB.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("user_id = ?", user.ID).
+		Delete(&identities).Error; err != nil {
+		return nil, err
+	}
+
+	var linked []string
+	for _, removed := range identities {
+		if removed.FirebaseUID != user.FirebaseUID && !slices.Contains(linked, removed.FirebaseUID) {
+			linked = append(linked, removed.FirebaseUID)
+		}
+	}
+	return linked, nil
+}
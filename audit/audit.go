@@ -0,0 +1,165 @@
+// Package audit keeps an append-only trail of authentication and security
+// events: who did what to whom, from where, and whether it worked.
+// Controllers record events; admins read them at /api/auth/admin/audit-events.
+package audit
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Actions, named <subject>.<verb>
+const (
+	ActionRegister             = "user.register"
+	ActionLogin                = "user.login"  // metadata.method: the sign-in provider (password, google.com, magic_link, webauthn, ...)
+	ActionIdPSignIn            = "idp.sign_in" // the local identity provider issued an ID token, the login follows at /login
+	ActionLogout               = "user.logout"
+	ActionProfileUpdate        = "user.profile_update" // metadata.changes: the changed fields
+	ActionAccountDelete        = "user.delete"
+	ActionPasswordResetRequest = "password.reset_request"
+	ActionPasswordReset        = "password.reset"
+	ActionEmailVerify          = "email.verify"
+	ActionMagicLinkRequest     = "magic_link.request"
+	ActionSessionCreate        = "session.create" // browser session cookie
+	ActionSessionRevoke        = "session.revoke"
+	ActionTokenReuse           = "token.reuse" // a consumed refresh token was presented again
+	ActionMFAEnroll            = "mfa.enroll"
+	ActionMFADisable           = "mfa.disable"
+	ActionPasskeyRegister      = "passkey.register"
+	ActionPasskeyDelete        = "passkey.delete"
+	ActionAPIKeyCreate         = "api_key.create"
+	ActionAPIKeyRotate         = "api_key.rotate"
+	ActionAPIKeyRevoke         = "api_key.revoke"
+	ActionIdentityLink         = "identity.link"
+	ActionIdentityUnlink       = "identity.unlink"
+	ActionRoleCreate           = "role.create"
+	ActionRolePermissions      = "role.permissions"
+	ActionRoleGrant            = "role.grant"
+	ActionRoleRevoke           = "role.revoke"
+	ActionAccountUnlock        = "lockout.unlock"
+	ActionImpersonationStart   = "impersonation.start"
+	ActionImpersonationStop    = "impersonation.stop"
+	ActionConsentGrant         = "oauth.consent_grant" // a third-party client was authorized
+	ActionConsentRevoke        = "oauth.consent_revoke"
+	ActionAuditExport          = "audit.export"
+)
+
+// Target types
+const (
+	TargetUser   = "user"
+	TargetAPIKey = "api_key"
+	TargetRole   = "role"
+)
+
+// Event is what a controller knows about an action; Record adds the
+// request details
+type Event struct {
+	Action     string
+	Outcome    string
+	ActorID    *uint // defaults to the authenticated actor of the request
+	TargetType string
+	TargetID   string
+	Metadata   map[string]interface{}
+}
+
+// Record appends an event for the request. Failures to write are logged,
+// never returned, so auditing cannot break authentication. Metadata must
+// not hold secrets (passwords, tokens, codes).
+func Record(c *fiber.Ctx, event Event) {
+	if event.ActorID == nil {
+		if actorID, ok := c.Locals("actor_id").(uint); ok {
+			event.ActorID = &actorID
+		}
+	}
+	// impersonated requests are done by the admin, on behalf of the user
+	if impersonated, _ := c.Locals("impersonated").(bool); impersonated {
+		if event.Metadata == nil {
+			event.Metadata = map[string]interface{}{}
+		}
+		event.Metadata["on_behalf_of"] = c.Locals("user_id")
+	}
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	requestID, _ := c.Locals("requestid").(string)
+	if requestID == "" {
+		requestID = c.Get(fiber.HeaderXRequestID)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&models.AuditEvent{
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Outcome:    event.Outcome,
+		IP:         c.IP(),
+		UserAgent:  userAgent,
+		RequestID:  requestID,
+		Metadata:   event.Metadata,
+	}).Error; err != nil {
+		log.Printf("⚠️  Audit event %s (%s) not recorded: %v", event.Action, event.Outcome, err)
+	}
+}
+
+// userTarget formats a user ID as a target; 0 means no target
+func userTarget(userID uint) (string, string) {
+	if userID == 0 {
+		return "", ""
+	}
+	return TargetUser, strconv.FormatUint(uint64(userID), 10)
+}
+
+// Success records a completed action on a user. Without an authenticated
+// actor (logins, registration) the user is taken as the actor.
+func Success(c *fiber.Ctx, action string, userID uint, metadata map[string]interface{}) {
+	targetType, targetID := userTarget(userID)
+	event := Event{
+		Action:     action,
+		Outcome:    models.AuditSuccess,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   metadata,
+	}
+	if _, ok := c.Locals("actor_id").(uint); !ok && userID != 0 {
+		event.ActorID = &userID
+	}
+	Record(c, event)
+}
+
+// Requested records an action anyone may ask for on a user's behalf (reset
+// emails, magic links). The caller is not proven to be the user, so no actor
+// is recorded.
+func Requested(c *fiber.Ctx, action string, userID uint, metadata map[string]interface{}) {
+	targetType, targetID := userTarget(userID)
+	Record(c, Event{
+		Action:     action,
+		Outcome:    models.AuditSuccess,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   metadata,
+	})
+}
+
+// Failure records a refused action on a user (0 when unknown), with the
+// reason in the metadata
+func Failure(c *fiber.Ctx, action string, userID uint, reason string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["reason"] = reason
+
+	targetType, targetID := userTarget(userID)
+	Record(c, Event{
+		Action:     action,
+		Outcome:    models.AuditFailure,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   metadata,
+	})
+}
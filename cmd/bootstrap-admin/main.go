@@ -0,0 +1,64 @@
+// Command bootstrap-admin grants the admin role to a registered user, so the
+// first admin can be created before anyone can call the admin API.
+//
+//	go run ./cmd/bootstrap-admin -email you@example.com
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	dotenv "Auth/config"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/models"
+	"Auth/roles"
+	"Auth/tenancy"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the registered user to promote")
+	flag.Parse()
+	if *email == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// same environment as the API server
+	if os.Getenv("GO_ENV") == "" {
+		dotenv.SetDotenv()
+	}
+
+	database.Connect()
+	defer database.Close()
+	database.SeedRoles()
+	database.SeedPermissions()
+	database.SeedTenants()
+	identity.Init()
+
+	// emails are unique across tenants; the admin administers their own board
+	ctx := tenancy.System(context.Background())
+
+	var user models.User
+	if err := database.DB.WithContext(ctx).Where("LOWER(email) = LOWER(?)", *email).First(&user).Error; err != nil {
+		log.Fatalf("❌ No user with email %s, register the account first", *email)
+	}
+
+	promoted, changed, err := roles.Grant(ctx, user.ID, roles.Admin)
+	if err != nil {
+		log.Fatalf("❌ Failed to grant admin role: %v", err)
+	}
+	if !changed {
+		log.Printf("✅ %s is already an admin", promoted.Email)
+	} else {
+		log.Printf("✅ Granted admin role to %s (user %d)", promoted.Email, promoted.ID)
+	}
+
+	// also repairs claims of an admin granted while the provider was unreachable
+	if err := roles.SyncClaims(ctx, promoted); err != nil {
+		log.Fatalf("❌ Admin role saved, but syncing custom claims failed: %v", err)
+	}
+	log.Println("✅ Custom claims synced, the role applies from the next sign-in")
+}
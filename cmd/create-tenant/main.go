@@ -0,0 +1,66 @@
+// Command create-tenant adds a job board, or updates the name and domain of
+// an existing one. Tenants are provisioned by operators, not through the API,
+// since board admins must never reach other boards.
+//
+//	go run ./cmd/create-tenant -slug nord -name "Nord Jobs" -domain jobs-nord.example.com
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	dotenv "Auth/config"
+	"Auth/database"
+	"Auth/models"
+	"Auth/tenancy"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func main() {
+	slug := flag.String("slug", "", "short name, used in the /t/<slug>/ path prefix")
+	name := flag.String("name", "", "display name")
+	domain := flag.String("domain", "", "host name the board is served on (optional)")
+	disable := flag.Bool("disable", false, "stop serving the board")
+	flag.Parse()
+	if !slugPattern.MatchString(*slug) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// same environment as the API server
+	if os.Getenv("GO_ENV") == "" {
+		dotenv.SetDotenv()
+	}
+
+	database.Connect()
+	defer database.Close()
+	database.SeedTenants()
+
+	db := database.DB.WithContext(tenancy.System(context.Background()))
+
+	var tenant models.Tenant
+	if err := db.Where("slug = ?", *slug).Attrs(models.Tenant{Name: *slug}).FirstOrInit(&tenant).Error; err != nil {
+		log.Fatalf("❌ Failed to load tenant %s: %v", *slug, err)
+	}
+
+	if *name != "" {
+		tenant.Name = *name
+	}
+	if host := strings.ToLower(strings.TrimSpace(*domain)); host != "" {
+		tenant.Domain = &host
+	}
+	tenant.Status = models.StatusActive
+	if *disable {
+		tenant.Status = models.StatusInactive
+	}
+
+	if err := db.Save(&tenant).Error; err != nil {
+		log.Fatalf("❌ Failed to save tenant %s: %v", *slug, err)
+	}
+	log.Printf("✅ Tenant %s (id %d) saved, status %d", tenant.Slug, tenant.ID, tenant.Status)
+}
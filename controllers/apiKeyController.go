@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/membership"
+	"Auth/models"
+	"Auth/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAPIKeyLifetimeDays = 365
+	apiKeyCreateAttempts  = 3 // the public prefix is short, so it can collide
+)
+
+// createAPIKey generates the secret of apiKey and saves it, drawing a new
+// key when the prefix is already taken. Returns the key to show once.
+func createAPIKey(tx *gorm.DB, apiKey *models.APIKey) (string, error) {
+	var err error
+	for attempt := 0; attempt < apiKeyCreateAttempts; attempt++ {
+		var key string
+		if key, apiKey.Prefix, err = utils.GenerateAPIKey(); err != nil {
+			return "", err
+		}
+		apiKey.KeyHash = utils.HashToken(key)
+
+		// a savepoint, so a conflict does not abort the caller's transaction
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(apiKey).Error
+		})
+		if err == nil {
+			return key, nil
+		}
+		if !strings.Contains(err.Error(), "duplicate") && !strings.Contains(err.Error(), "unique") {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+// apiKeyResponse is an API key as shown to its owner; key is only set on
+// creation and rotation
+func apiKeyResponse(apiKey models.APIKey, key string) fiber.Map {
+	data := fiber.Map{
+		"id":           apiKey.ID,
+		"name":         apiKey.Name,
+		"prefix":       apiKey.Prefix,
+		"company_id":   apiKey.CompanyID,
+		"scopes":       apiKey.ScopeList(),
+		"expires_at":   apiKey.ExpiresAt,
+		"last_used_at": apiKey.LastUsedAt,
+		"last_used_ip": apiKey.LastUsedIP,
+		"revoked_at":   apiKey.RevokedAt,
+		"created_at":   apiKey.CreatedAt,
+	}
+	if key != "" {
+		data["key"] = key
+	}
+	return data
+}
+
+// auditAPIKey records a change to a key; the prefix identifies it in logs
+func auditAPIKey(c *fiber.Ctx, action string, apiKey models.APIKey, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["prefix"] = apiKey.Prefix
+	audit.Record(c, audit.Event{
+		Action:     action,
+		Outcome:    models.AuditSuccess,
+		TargetType: audit.TargetAPIKey,
+		TargetID:   strconv.FormatUint(uint64(apiKey.ID), 10),
+		Metadata:   metadata,
+	})
+}
+
+// findOwnAPIKey loads an API key of the current user by route :id
+func findOwnAPIKey(c *fiber.Ctx, userID uint) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := database.DB.WithContext(c.UserContext()).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Params("id"), userID).
+		First(&apiKey).Error
+	return &apiKey, err
+}
+
+// CreateAPIKey issues a new key for the current user or, for admins, for a
+// company. The key is returned once and cannot be retrieved again.
+func CreateAPIKey(c *fiber.Ctx) error {
+	type Req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 = no expiry
+		CompanyID     *uint    `json:"company_id"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Name and at least one scope are required",
+		})
+	}
+	for _, scope := range req.Scopes {
+		if !utils.ValidAPIKeyScope(scope) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Unknown scope " + scope,
+				"data":    fiber.Map{"allowed_scopes": utils.APIKeyScopes},
+			})
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyLifetimeDays {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "expires_in_days must be between 0 and 365",
+		})
+	}
+
+	if req.CompanyID != nil {
+		var company models.Company
+		if err := database.DB.WithContext(c.UserContext()).First(&company, *req.CompanyID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Company not found",
+			})
+		}
+		// company keys are provisioned by the company's owners (or admins)
+		if ok, err := membership.Require(c, company.ID, membership.ProfileEditors); !ok {
+			return err
+		}
+	}
+
+	apiKey := models.APIKey{
+		Name:      req.Name,
+		UserID:    userID,
+		CompanyID: req.CompanyID,
+		Scopes:    strings.Join(req.Scopes, " "),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	key, err := createAPIKey(database.DB.WithContext(c.UserContext()), &apiKey)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save API key",
+		})
+	}
+	auditAPIKey(c, audit.ActionAPIKeyCreate, apiKey, map[string]interface{}{
+		"name":       apiKey.Name,
+		"company_id": apiKey.CompanyID,
+		"scopes":     apiKey.Scopes,
+	})
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "API key created. Store it now, it will not be shown again",
+		"data":    apiKeyResponse(apiKey, key),
+	})
+}
+
+// ListAPIKeys returns the current user's keys (without secrets)
+func ListAPIKeys(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var apiKeys []models.APIKey
+	if err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", userID).Order("id DESC").Find(&apiKeys).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		data = append(data, apiKeyResponse(apiKey, ""))
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "API keys retrieved",
+		"data":    data,
+	})
+}
+
+// RotateAPIKey replaces a key with a new secret, keeping name, owner, scopes
+// and expiry. The old key stops working immediately.
+func RotateAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	old, err := findOwnAPIKey(c, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "API key not found",
+		})
+	}
+
+	rotated := models.APIKey{
+		Name:      old.Name,
+		UserID:    old.UserID,
+		CompanyID: old.CompanyID,
+		Scopes:    old.Scopes,
+		ExpiresAt: old.ExpiresAt,
+	}
+
+	var key string
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(old).Where("revoked_at IS NULL").Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		var err error
+		key, err = createAPIKey(tx, &rotated)
+		return err
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to rotate API key",
+		})
+	}
+	auditAPIKey(c, audit.ActionAPIKeyRotate, rotated, map[string]interface{}{"replaces": old.ID})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "API key rotated. Store the new key now, it will not be shown again",
+		"data":    apiKeyResponse(rotated, key),
+	})
+}
+
+// RevokeAPIKey permanently disables a key of the current user
+func RevokeAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	apiKey, err := findOwnAPIKey(c, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "API key not found",
+		})
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Model(apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to revoke API key",
+		})
+	}
+	auditAPIKey(c, audit.ActionAPIKeyRevoke, *apiKey, nil)
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "API key revoked",
+	})
+}
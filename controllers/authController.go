@@ -1,33 +1,29 @@
 package controllers
 
 import (
+	"Auth/accounts"
+	"Auth/audit"
 	"Auth/database"
 	"Auth/firebase"
+	"Auth/identity"
+	"Auth/middleware"
 	"Auth/models"
+	"Auth/roles"
+	"Auth/tenancy"
 	"Auth/utils"
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"log"
+	"sort"
 	"time"
 
-	"firebase.google.com/go/v4/auth"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
-
 // register a new user
 func Register(c *fiber.Ctx) error {
 	type Req struct {
@@ -47,9 +43,9 @@ func Register(c *fiber.Ctx) error {
 			"message": "Invalid input",
 		})
 	}
-	// ✅ Prevent duplicate email / username
+	// ✅ Prevent duplicate email / username (unique across tenants)
 	var existing models.User
-	if err := database.DB.
+	if err := database.DB.WithContext(tenancy.System(c.UserContext())).
 		Where("email = ? OR username = ?", req.Email, req.Username).
 		First(&existing).Error; err == nil {
 
@@ -59,16 +55,23 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// ✅ Create Firebase user
-	authClient := firebase.GetAuthClient()
+	// ✅ Hash password for native /login (configured bcrypt/argon2id)
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to hash password",
+		})
+	}
+
+	// ✅ Create identity provider user (Firebase or local)
+	idp := identity.Get()
 	ctx := context.Background()
 
-	firebaseUser, err := authClient.CreateUser(ctx,
-		(&auth.UserToCreate{}).
-			Email(req.Email).
-			Password(req.Password),
-		//	DisplayName(req.Username),
-	)
+	firebaseUser, err := idp.CreateUser(ctx, identity.UserToCreate{
+		Email:    req.Email,
+		Password: req.Password,
+	})
 
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
@@ -79,8 +82,8 @@ func Register(c *fiber.Ctx) error {
 
 	// ✅ Load default role
 	var role models.Role
-	if err := database.DB.Where("name = ?", "user").First(&role).Error; err != nil {
-		authClient.DeleteUser(ctx, firebaseUser.UID)
+	if err := database.DB.WithContext(c.UserContext()).Where("name = ?", "user").First(&role).Error; err != nil {
+		idp.DeleteUser(ctx, firebaseUser.UID)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Role not found",
@@ -88,20 +91,21 @@ func Register(c *fiber.Ctx) error {
 	}
 
 	// ✅ Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c.UserContext()).Begin()
 
-	// ✅ Create user (NO password stored)
+	// ✅ Create user (only the password hash is stored)
 	user := models.User{
 		FirebaseUID: firebaseUser.UID,
 		Email:       req.Email,
 		Username:    req.Username,
+		Password:    passwordHash,
 		Provider:    "password",
 		Roles:       []models.Role{role},
 	}
 
 	if err := tx.Create(&user).Error; err != nil {
 		tx.Rollback()
-		authClient.DeleteUser(ctx, firebaseUser.UID)
+		idp.DeleteUser(ctx, firebaseUser.UID)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": err.Error(),
@@ -120,7 +124,7 @@ func Register(c *fiber.Ctx) error {
 
 	if err := tx.Create(&userDetails).Error; err != nil {
 		tx.Rollback()
-		authClient.DeleteUser(ctx, firebaseUser.UID)
+		idp.DeleteUser(ctx, firebaseUser.UID)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create user details",
@@ -128,9 +132,15 @@ func Register(c *fiber.Ctx) error {
 	}
 
 	tx.Commit()
+	audit.Success(c, audit.ActionRegister, user.ID, map[string]interface{}{"method": "password"})
+
+	// ✅ Record the password sign-in method
+	if err := accounts.Sync(c.UserContext(), user.ID, firebaseUser); err != nil {
+		log.Printf("⚠️  Identity sync failed for user %d: %v", user.ID, err)
+	}
 
 	// ✅ Reload roles
-	database.DB.Preload("Roles").First(&user, user.ID)
+	database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, user.ID)
 
 	// ✅ Set Firebase custom claims
 	claims := map[string]interface{}{
@@ -138,19 +148,20 @@ func Register(c *fiber.Ctx) error {
 		"role":    "user",
 	}
 
-	if err := authClient.SetCustomUserClaims(ctx, user.FirebaseUID, claims); err != nil {
+	if err := idp.SetCustomUserClaims(ctx, user.FirebaseUID, claims); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to set custom claims",
 		})
 	}
 
-	// ✅ Generate JWT
-	jwtToken, err := utils.GenerateJWT(
-		user.ID,
-		user.Email,
-		user.FirebaseUID,
-		"user")
+	// ✅ Send email verification link (registration still succeeds if mail fails)
+	if err := sendVerificationEmail(c, user); err != nil {
+		log.Printf("⚠️  Verification email to user %d failed: %v", user.ID, err)
+	}
+
+	// ✅ Generate JWT + refresh token (starts a new session)
+	pair, err := issueTokenPair(c, user, user.Provider)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -163,16 +174,19 @@ func Register(c *fiber.Ctx) error {
 		"success": true,
 		"message": "Registration successful",
 		"data": fiber.Map{
-			"token": jwtToken,
+			"token":              pair.Access.Token,
+			"refresh_token":      pair.Refresh.Token,
+			"refresh_expires_at": pair.Refresh.ExpiresAt,
 			"user": fiber.Map{
-				"id":       user.ID,
-				"uid":      user.FirebaseUID,
-				"email":    user.Email,
-				"username": user.Username,
-				"name":     userDetails.Name,
-				"lastname": userDetails.Lastname,
-				"provider": user.Provider,
-				"roles":    []string{"user"},
+				"id":             user.ID,
+				"uid":            user.FirebaseUID,
+				"email":          user.Email,
+				"username":       user.Username,
+				"name":           userDetails.Name,
+				"lastname":       userDetails.Lastname,
+				"provider":       user.Provider,
+				"roles":          firebase.GetRoleNames(user.Roles),
+				"email_verified": false,
 			},
 		},
 	})
@@ -192,7 +206,7 @@ func GetProfile(c *fiber.Ctx) error {
 
 	// Get user from database
 	var user models.User
-	if err := database.DB.Preload("Roles").First(&user, userID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, userID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "User not found",
@@ -201,34 +215,29 @@ func GetProfile(c *fiber.Ctx) error {
 
 	// Get user details
 	var userDetails models.User_Details
-	if err := database.DB.Where("user_id = ?", userID).First(&userDetails).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", userID).First(&userDetails).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "User details not found",
 		})
 	}
 
-	// Get role name
-	roleName := "user"
-	if len(user.Roles) > 0 {
-		roleName = user.Roles[0].Name
-	}
-
 	// Return profile data
 	return c.Status(200).JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"id":       user.ID,
-			"uid":      user.FirebaseUID,
-			"email":    user.Email,
-			"username": user.Username,
-			"name":     userDetails.Name,
-			"lastname": userDetails.Lastname,
-			"gender":   userDetails.Gender,
-			"age":      userDetails.Age,
-			"dob":      userDetails.Dob,
-			"provider": user.Provider,
-			"roles":    []string{roleName},
+			"id":                user.ID,
+			"uid":               user.FirebaseUID,
+			"email":             user.Email,
+			"username":          user.Username,
+			"name":              userDetails.Name,
+			"lastname":          userDetails.Lastname,
+			"gender":            userDetails.Gender,
+			"age":               userDetails.Age,
+			"dob":               userDetails.Dob,
+			"provider":          user.Provider,
+			"roles":             firebase.GetRoleNames(user.Roles),
+			"email_verified_at": user.EmailVerifiedAt,
 		},
 	})
 }
@@ -283,7 +292,7 @@ func UpdateProfile(c *fiber.Ctx) error {
 	// 4. Load user + roles
 	// =========================
 	var user models.User
-	if err := database.DB.Preload("Roles").First(&user, userID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, userID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "User not found",
@@ -291,14 +300,14 @@ func UpdateProfile(c *fiber.Ctx) error {
 	}
 
 	var userDetails models.User_Details
-	err := database.DB.Where("user_id = ?", userID).First(&userDetails).Error
+	err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", userID).First(&userDetails).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		userDetails = models.User_Details{
 			UserID: userID,
 		}
 
-		if err := database.DB.Create(&userDetails).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).Create(&userDetails).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"success": false,
 				"message": "Failed to create user details",
@@ -316,7 +325,7 @@ func UpdateProfile(c *fiber.Ctx) error {
 	// =========================
 	if req.Username != nil && *req.Username != user.Username {
 		var count int64
-		database.DB.Model(&models.User{}).
+		database.DB.WithContext(tenancy.System(c.UserContext())).Model(&models.User{}).
 			Where("username = ? AND id != ?", *req.Username, userID).
 			Count(&count)
 
@@ -331,7 +340,7 @@ func UpdateProfile(c *fiber.Ctx) error {
 	// =========================
 	// 6. Transaction start
 	// =========================
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c.UserContext()).Begin()
 	if tx.Error != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -401,26 +410,33 @@ func UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 
+	changes := make([]string, 0, len(updates)+1)
+	if req.Username != nil {
+		changes = append(changes, "username")
+	}
+	for field := range updates {
+		changes = append(changes, field)
+	}
+	sort.Strings(changes)
+	audit.Success(c, audit.ActionProfileUpdate, userID, map[string]interface{}{"changes": changes})
+
 	// =========================
 	// 10. Collect roles
 	// =========================
-	roles := []string{}
-	for _, r := range user.Roles {
-		roles = append(roles, r.Name)
-	}
-	if len(roles) == 0 {
-		roles = append(roles, "user")
+	roleNames := firebase.GetRoleNames(user.Roles)
+	if len(roleNames) == 0 {
+		roleNames = append(roleNames, roles.User)
 	}
 
 	// =========================
 	// 11. Generate new JWT
 	// =========================
-	//roleName := roles[0]
 	token, err := utils.GenerateJWT(
 		user.ID,
 		user.Email,
 		user.FirebaseUID,
-		userDetails.Name,
+		roles.Primary(user.Roles),
+		user.TenantID,
 	)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -448,7 +464,7 @@ func UpdateProfile(c *fiber.Ctx) error {
 				"age":      userDetails.Age,
 				"dob":      userDetails.Dob,
 				"provider": user.Provider,
-				"roles":    roles,
+				"roles":    roleNames,
 			},
 		},
 	})
@@ -472,141 +488,49 @@ func UpdateEmaile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 	firebaseUID := c.Locals("firebase_uid").(string)
 
-	// Check if email is already taken
+	// Check if email is already taken (on any board)
 	var existing models.User
-	if err := database.DB.Where("email = ? AND id != ?", req.Email, userID).First(&existing).Error; err == nil {
+	if err := database.DB.WithContext(tenancy.System(c.UserContext())).Where("email = ? AND id != ?", req.Email, userID).First(&existing).Error; err == nil {
 		return c.Status(409).JSON(fiber.Map{
 			"success": false,
 			"message": "Email already taken",
 		})
 	}
 
-	// Update Firebase
-	authClient := firebase.GetAuthClient()
+	// Update identity provider
 	ctx := context.Background()
 
-	if _, err := authClient.UpdateUser(ctx, firebaseUID, (&auth.UserToUpdate{}).Email(req.Email)); err != nil {
+	if _, err := identity.Get().UpdateUser(ctx, firebaseUID, identity.UserToUpdate{Email: &req.Email}); err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to update Firebase: " + err.Error(),
+			"message": "Failed to update identity provider: " + err.Error(),
 		})
 	}
 
 	// Update database
-	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("email", req.Email).Error; err != nil {
+	// (a new address has to be verified again)
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email":             req.Email,
+		"email_verified_at": nil,
+	}).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to update database",
 		})
 	}
 
-	return c.Status(200).JSON(fiber.Map{
-		"success": true,
-		"message": "Email updated successfully",
-	})
-}
-
-// UpdatePassword - Separate function to update password only
-func ForgotPasswordByEmail(c *fiber.Ctx) error {
-	// 1. Request structure
-	type Request struct {
-		Email string `json:"email"`
-	}
-
-	var req Request
-
-	// 2. Parse request body
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"message": "Invalid request body",
-		})
-	}
-
-	// 3. Validate email
-	if req.Email == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"message": "Email is required",
-		})
-	}
-	// 4. Get Firebase API key
-	apiKey := os.Getenv("FIREBASE_API_KEY_ID")
-	if apiKey == "" {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"message": "Server configuration error",
-		})
-	}
-
-	// 5. Prepare Firebase payload
-	payload := map[string]string{
-		"requestType": "PASSWORD_RESET",
-		"email":       req.Email,
-	}
+	audit.Success(c, audit.ActionProfileUpdate, userID, map[string]interface{}{"changes": []string{"email"}})
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to prepare request",
-		})
-	}
-
-	// 6. Firebase endpoint
-	url := fmt.Sprintf(
-		"https://identitytoolkit.googleapis.com/v1/accounts:sendOobCode?key=%s",
-		apiKey,
-	)
-
-	// 7. Send request to Firebase
-	resp, err := http.Post(
-		url,
-		"application/json",
-		bytes.NewBuffer(payloadBytes),
-	)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to connect to authentication service",
-		})
-	}
-	defer resp.Body.Close()
-
-	// 8. Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to read response",
-		})
-	}
-
-	// 9. Handle Firebase errors
-
-	if resp.StatusCode != http.StatusOK {
-		var firebaseErr struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-
-		_ = json.Unmarshal(body, &firebaseErr)
-
-		msg := firebaseErr.Error.Message
-		if msg == "" {
-			msg = "Failed to send password reset email"
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err == nil {
+		if err := sendVerificationEmail(c, user); err != nil {
+			log.Printf("⚠️  Verification email to user %d failed: %v", user.ID, err)
 		}
-
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"message": msg,
-		})
 	}
-	// 10. Success response
+
 	return c.Status(200).JSON(fiber.Map{
 		"success": true,
-		"message": "Password reset email sent successfully",
+		"message": "Email updated successfully",
 	})
 }
 
@@ -631,10 +555,10 @@ func DeleteCurrentUser(c *fiber.Ctx) error {
 	}
 
 	// Get database connection
-	db := database.DB
+	db := database.DB.WithContext(c.UserContext())
 
-	// Get Firebase Auth Client
-	firebaseAuth := firebase.GetAuthClient()
+	// Get identity provider
+	idp := identity.Get()
 
 	// Find the user
 	var user models.User
@@ -655,7 +579,7 @@ func DeleteCurrentUser(c *fiber.Ctx) error {
 
 	// 1. Delete Firebase Authentication
 	if user.FirebaseUID != "" {
-		if err := firebaseAuth.DeleteUser(ctx, user.FirebaseUID); err != nil {
+		if err := idp.DeleteUser(ctx, user.FirebaseUID); err != nil {
 			// Log error but continue (user might already be deleted in Firebase)
 			// You can add logging here if needed
 		}
@@ -669,6 +593,19 @@ func DeleteCurrentUser(c *fiber.Ctx) error {
 		})
 	}
 
+	audit.Success(c, audit.ActionAccountDelete, user.ID, nil)
+
+	// 3. Unlink sign-in methods and delete the accounts linked to the user
+	linked, err := accounts.Forget(c.UserContext(), &user)
+	if err != nil {
+		log.Printf("⚠️  Identities of deleted user %d not removed: %v", user.ID, err)
+	}
+	for _, uid := range linked {
+		if err := idp.DeleteUser(ctx, uid); err != nil {
+			log.Printf("⚠️  Linked account %s of deleted user %d not deleted: %v", uid, user.ID, err)
+		}
+	}
+
 	// Clear authentication cookie (if you use cookies)
 	c.Cookie(&fiber.Cookie{
 		Name:     "token",
@@ -685,8 +622,98 @@ func DeleteCurrentUser(c *fiber.Ctx) error {
 	})
 }
 
+// Logout revokes the credentials of the current request.
+// With "all": true every session of the user is revoked, including
+// Firebase refresh tokens, which logs the user out on every device.
 func Logout(c *fiber.Ctx) error {
-	// Clear the authentication cookie by setting it to expire immediately
+	type Req struct {
+		RefreshToken string `json:"refresh_token"` // lets Firebase-authenticated clients end their session
+		All          bool   `json:"all"`
+	}
+
+	var req Req
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid input",
+			})
+		}
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	// 1. Revoke the access token and the session it was issued for
+	if claims, ok := c.Locals("jwt_claims").(*utils.JWTClaims); ok {
+		if err := revokeAccessToken(claims); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to revoke token",
+			})
+		}
+		if claims.SessionID != 0 {
+			if err := revokeSession(claims.SessionID, "logout"); err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"success": false,
+					"message": "Failed to revoke session",
+				})
+			}
+		}
+	}
+
+	// 2. Revoke the session of the presented refresh token (must be ours)
+	if req.RefreshToken != "" {
+		var token models.RefreshToken
+		if err := database.DB.WithContext(c.UserContext()).
+			Preload("Session").
+			Where("token_hash = ?", utils.HashToken(req.RefreshToken)).
+			First(&token).Error; err == nil && token.Session.UserID == userID {
+			if err := revokeSession(token.SessionID, "logout"); err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"success": false,
+					"message": "Failed to revoke session",
+				})
+			}
+		}
+	}
+
+	// 3. Log out everywhere
+	if req.All {
+		if err := revokeUserSessions(userID, "logout_all"); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to revoke sessions",
+			})
+		}
+
+		firebaseUID, _ := c.Locals("firebase_uid").(string)
+		if firebaseUID != "" {
+			if err := identity.Get().RevokeRefreshTokens(context.Background(), firebaseUID); err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"success": false,
+					"message": "Failed to revoke Firebase sessions",
+				})
+			}
+		}
+	}
+
+	// 4. Revoke the session cookie of a browser client
+	if token, ok := c.Locals("session_cookie").(*identity.Token); ok {
+		if err := revokeSessionCookie(c.Cookies(middleware.SessionCookieName), userID, token); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to revoke session cookie",
+			})
+		}
+	}
+
+	// Clear the authentication cookies by setting them to expire immediately
 	c.Cookie(&fiber.Cookie{
 		Name:     "token",
 		Value:    "",
@@ -695,6 +722,8 @@ func Logout(c *fiber.Ctx) error {
 		Secure:   true, // Set to true in production with HTTPS
 		SameSite: "Lax",
 	})
+	clearSessionCookies(c)
+	audit.Success(c, audit.ActionLogout, userID, map[string]interface{}{"all": req.All})
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Logged out successfully",
@@ -2,6 +2,7 @@ package company
 
 import (
 	"Auth/database"
+	"Auth/membership"
 	"Auth/models"
 	presenters "Auth/presenter"
 	"fmt"
@@ -34,7 +35,7 @@ func GetAllCompany(c *fiber.Ctx) error {
 
 	// Get total count
 	var totalItems int64
-	if err := database.DB.Model(&models.Company{}).Count(&totalItems).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.Company{}).Count(&totalItems).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "ຜິດພາດໃນການດຶງຂໍ້ມູນ",
@@ -42,7 +43,7 @@ func GetAllCompany(c *fiber.Ctx) error {
 	}
 
 	// Get paginated data
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -83,7 +84,7 @@ func GetCompanyByID(c *fiber.Ctx) error {
 	var company models.Company
 
 	// query from database
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Where("id = ? AND status = ?", id, 1).
 		First(&company).Error; err != nil {
 
@@ -146,9 +147,22 @@ func CreateCompany(c *fiber.Ctx) error {
 		Logo:        logoPath,
 	}
 
-	// Insert into DB
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
 
-	if err := database.DB.Create(&company).Error; err != nil {
+	// Insert into DB, the creator becomes its first owner
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&company).Error; err != nil {
+			return err
+		}
+		return membership.AddOwner(tx, company.ID, userID)
+	})
+	if err != nil {
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
 			return c.Status(400).JSON(fiber.Map{
 				"success": false,
@@ -177,13 +191,17 @@ func UpdateCompany(c *fiber.Ctx) error {
 	}
 
 	var company models.Company
-	if err := database.DB.First(&company, companyID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&company, companyID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Company not found",
 		})
 	}
 
+	if ok, err := membership.Require(c, company.ID, membership.ProfileEditors); !ok {
+		return err
+	}
+
 	name := strings.TrimSpace(c.FormValue("name"))
 	email := strings.TrimSpace(c.FormValue("email"))
 	address := strings.TrimSpace(c.FormValue("address"))
@@ -216,7 +234,7 @@ func UpdateCompany(c *fiber.Ctx) error {
 	}
 
 	// Save instead of Updates
-	if err := database.DB.Save(&company).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Save(&company).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update company"})
 	}
 
@@ -234,16 +252,20 @@ func DeleteCompany(c *fiber.Ctx) error {
 	}
 
 	var company models.Company
-	if err := database.DB.First(&company, companyID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&company, companyID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Company not found",
 		})
 	}
 
+	if ok, err := membership.Require(c, company.ID, membership.ProfileEditors); !ok {
+		return err
+	}
+
 	company.Status = 0
-	database.DB.Save(&company)
-	if err := database.DB.Delete(&company).Error; err != nil {
+	database.DB.WithContext(c.UserContext()).Save(&company)
+	if err := database.DB.WithContext(c.UserContext()).Delete(&company).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to delete company",
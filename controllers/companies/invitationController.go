@@ -0,0 +1,381 @@
+package company
+
+import (
+	"Auth/database"
+	"Auth/mailer"
+	"Auth/membership"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// invitationTTL reads COMPANY_INVITATION_TTL_HOURS (default 168, a week)
+func invitationTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("COMPANY_INVITATION_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// invitationError maps membership invitation errors to responses
+func invitationError(c *fiber.Ctx, err error) error {
+	status, message := 500, "Database error"
+	switch {
+	case errors.Is(err, membership.ErrInvalidRole):
+		status, message = 400, "Role must be owner, recruiter or viewer"
+	case errors.Is(err, membership.ErrAlreadyMember):
+		status, message = 409, "This person is already a member of the company"
+	case errors.Is(err, membership.ErrInvitationNotFound):
+		status, message = 404, "Invitation not found"
+	case errors.Is(err, membership.ErrInvitationClosed):
+		status, message = 409, "Invitation was already accepted or revoked"
+	case errors.Is(err, membership.ErrInvitationInvalid):
+		status, message = 400, "Invalid or expired invitation link"
+	case errors.Is(err, membership.ErrInvitationEmail):
+		status, message = 403, "This invitation was sent to another email address"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
+// invitationResponse is an invitation as shown to the company's owners
+func invitationResponse(invitation models.CompanyInvitation) fiber.Map {
+	return fiber.Map{
+		"id":            invitation.ID,
+		"company_id":    invitation.CompanyID,
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"status":        invitation.Status(time.Now()),
+		"invited_by_id": invitation.InvitedByID,
+		"expires_at":    invitation.ExpiresAt,
+		"created_at":    invitation.CreatedAt,
+	}
+}
+
+// invitationAppURL is the frontend the invitation links open. Accepting needs
+// a signed-in user, so there is no API fallback, and links are never built
+// from the request, whose Host the caller controls. Answers 503 and returns
+// "" when APP_URL is not configured:
+//
+//	appURL, err := invitationAppURL(c)
+//	if appURL == "" {
+//		return err
+//	}
+func invitationAppURL(c *fiber.Ctx) (string, error) {
+	appURL, err := utils.AppURL()
+	if err != nil {
+		log.Printf("⚠️  Invitations unavailable: %v", err)
+		return "", c.Status(503).JSON(fiber.Map{
+			"success": false,
+			"message": "Invitations are not available",
+		})
+	}
+	return appURL, nil
+}
+
+// expiresIn words a link lifetime for emails: hours below a day, else days
+func expiresIn(ttl time.Duration) string {
+	hours := int(ttl.Hours() + 0.5)
+	switch {
+	case hours <= 1:
+		return "1 hour"
+	case hours < 24:
+		return fmt.Sprintf("%d hours", hours)
+	case hours < 48:
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", hours/24)
+}
+
+// sendInvitation mails the signed invitation link to the frontend's accept
+// page at appURL. The token expires with the invitation and carries its
+// nonce, so a resent link replaces the old one.
+func sendInvitation(c *fiber.Ctx, invitation models.CompanyInvitation, nonce, appURL string) error {
+	var company models.Company
+	if err := database.DB.WithContext(c.UserContext()).First(&company, invitation.CompanyID).Error; err != nil {
+		return err
+	}
+	var inviter models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&inviter, invitation.InvitedByID).Error; err != nil {
+		return err
+	}
+
+	ttl := time.Until(invitation.ExpiresAt)
+	token, err := utils.GenerateActionToken(utils.PurposeInvitation, 0, invitation.Email, ttl, map[string]string{
+		"invitation_id": strconv.FormatUint(uint64(invitation.ID), 10),
+		"nonce":         nonce,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := appURL + "/invitations/accept?token=" + url.QueryEscape(token)
+
+	return mailer.Get().Send(c.UserContext(), mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("Join %s", company.Name),
+		Text: fmt.Sprintf("Hi,\n\n%s invited you to join %s as %s. "+
+			"Sign in (or create an account) with this email address and open the link below:\n\n%s\n\n"+
+			"The link expires in %s. If you were not expecting this, ignore this email.\n",
+			inviter.Username, company.Name, invitation.Role, link, expiresIn(ttl)),
+	})
+}
+
+// invitationParams reads the :id and :invitationId route params
+func invitationParams(c *fiber.Ctx) (companyID, invitationID uint, ok bool) {
+	cid, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	iid, err := strconv.ParseUint(c.Params("invitationId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(cid), uint(iid), true
+}
+
+// CreateInvitation invites someone by email to join the company with a role
+func CreateInvitation(c *fiber.Ctx) error {
+	companyID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company ID",
+		})
+	}
+
+	type Req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A valid email is required",
+		})
+	}
+	if req.Role == "" {
+		req.Role = models.MemberViewer
+	}
+
+	var company models.Company
+	if err := database.DB.WithContext(c.UserContext()).First(&company, companyID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Company not found",
+		})
+	}
+
+	if ok, err := membership.Require(c, company.ID, membership.TeamManagers); !ok {
+		return err
+	}
+
+	appURL, err := invitationAppURL(c)
+	if appURL == "" {
+		return err
+	}
+
+	inviterID, _ := c.Locals("user_id").(uint)
+	invitation, nonce, err := membership.Invite(c.UserContext(), company.ID, inviterID, req.Email, req.Role, invitationTTL())
+	if err != nil {
+		return invitationError(c, err)
+	}
+
+	emailSent := true
+	if err := sendInvitation(c, *invitation, nonce, appURL); err != nil {
+		log.Printf("⚠️  Invitation email %d failed: %v", invitation.ID, err)
+		emailSent = false
+	}
+
+	data := invitationResponse(*invitation)
+	data["email_sent"] = emailSent
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation sent",
+		"data":    data,
+	})
+}
+
+// ListInvitations returns the open invitations of the company, expired
+// ones included so they can be resent
+func ListInvitations(c *fiber.Ctx) error {
+	companyID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company ID",
+		})
+	}
+
+	if ok, err := membership.Require(c, uint(companyID), membership.TeamManagers); !ok {
+		return err
+	}
+
+	var invitations []models.CompanyInvitation
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("company_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", companyID).
+		Order("created_at DESC").
+		Find(&invitations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(invitations))
+	for _, invitation := range invitations {
+		data = append(data, invitationResponse(invitation))
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitations retrieved",
+		"data":    data,
+	})
+}
+
+// ResendInvitation mails a fresh link and restarts the expiry; the previous
+// link stops working
+func ResendInvitation(c *fiber.Ctx) error {
+	companyID, invitationID, ok := invitationParams(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company or invitation ID",
+		})
+	}
+
+	if ok, err := membership.Require(c, companyID, membership.TeamManagers); !ok {
+		return err
+	}
+
+	appURL, err := invitationAppURL(c)
+	if appURL == "" {
+		return err
+	}
+
+	invitation, nonce, err := membership.Reissue(c.UserContext(), companyID, invitationID, invitationTTL())
+	if err != nil {
+		return invitationError(c, err)
+	}
+
+	if err := sendInvitation(c, *invitation, nonce, appURL); err != nil {
+		log.Printf("⚠️  Invitation email %d failed: %v", invitation.ID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to send invitation email",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation resent",
+		"data":    invitationResponse(*invitation),
+	})
+}
+
+// RevokeInvitation withdraws an open invitation
+func RevokeInvitation(c *fiber.Ctx) error {
+	companyID, invitationID, ok := invitationParams(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company or invitation ID",
+		})
+	}
+
+	if ok, err := membership.Require(c, companyID, membership.TeamManagers); !ok {
+		return err
+	}
+
+	if err := membership.RevokeInvitation(c.UserContext(), companyID, invitationID); err != nil {
+		return invitationError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation revoked",
+	})
+}
+
+// AcceptInvitation joins the signed-in user to the company with the token
+// from the invitation email. Accepts POST {"token": ...} or ?token=.
+func AcceptInvitation(c *fiber.Ctx) error {
+	type Req struct {
+		Token string `json:"token"`
+	}
+
+	var req Req
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid input",
+			})
+		}
+	}
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+	if req.Token == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Token is required",
+		})
+	}
+
+	claims, err := utils.ValidateActionToken(req.Token, utils.PurposeInvitation)
+	if err != nil {
+		return invitationError(c, membership.ErrInvitationInvalid)
+	}
+	invitationID, err := strconv.ParseUint(claims.Data["invitation_id"], 10, 64)
+	if err != nil {
+		return invitationError(c, membership.ErrInvitationInvalid)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	member, err := membership.Accept(c.UserContext(), uint(invitationID), claims.Data["nonce"], &user)
+	if err != nil {
+		return invitationError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation accepted",
+		"data":    member,
+	})
+}
@@ -0,0 +1,70 @@
+package company
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestExpiresIn(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{30 * time.Minute, "1 hour"},
+		{time.Hour, "1 hour"},
+		{5 * time.Hour, "5 hours"},
+		{22*time.Hour + 59*time.Minute, "23 hours"},
+		{24 * time.Hour, "1 day"},
+		{47 * time.Hour, "1 day"},
+		{168*time.Hour - time.Second, "7 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl.String(), func(t *testing.T) {
+			if got := expiresIn(tt.ttl); got != tt.want {
+				t.Errorf("expiresIn(%v) = %q, want %q", tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvitationAppURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		appURL     string
+		wantStatus int
+	}{
+		{"configured", "https://jobs.example.com", 200},
+		{"unset", "", 503},
+		{"not a url", "jobs.example.com", 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_URL", tt.appURL)
+
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error {
+				appURL, err := invitationAppURL(c)
+				if appURL == "" {
+					return err
+				}
+				return c.SendStatus(200)
+			})
+
+			// the Host of the request never becomes the link
+			req := httptest.NewRequest(fiber.MethodPost, "/", nil)
+			req.Host = "attacker.example"
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package company
+
+import (
+	"Auth/database"
+	"Auth/membership"
+	"Auth/models"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// memberError maps membership package errors to responses
+func memberError(c *fiber.Ctx, err error) error {
+	status, message := 500, "Database error"
+	switch {
+	case errors.Is(err, membership.ErrNotMember):
+		status, message = 404, "User is not a member of this company"
+	case errors.Is(err, membership.ErrLastOwner):
+		status, message = 409, "A company must keep at least one owner"
+	case errors.Is(err, membership.ErrInvalidRole):
+		status, message = 400, "Role must be owner, recruiter or viewer"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status, message = 404, "Company not found"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
+// memberParams reads the :id and :userId route params
+func memberParams(c *fiber.Ctx) (companyID, userID uint, ok bool) {
+	cid, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	uid, err := strconv.ParseUint(c.Params("userId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(cid), uint(uid), true
+}
+
+// ListMembers returns the team of a company
+func ListMembers(c *fiber.Ctx) error {
+	companyID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company ID",
+		})
+	}
+
+	if ok, err := membership.Require(c, uint(companyID), membership.Readers); !ok {
+		return err
+	}
+
+	var members []models.CompanyMember
+	if err := database.DB.WithContext(c.UserContext()).Preload("User").
+		Where("company_id = ?", companyID).
+		Order("id").
+		Find(&members).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(members))
+	for _, member := range members {
+		data = append(data, fiber.Map{
+			"user_id":    member.UserID,
+			"username":   member.User.Username,
+			"email":      member.User.Email,
+			"role":       member.Role,
+			"created_at": member.CreatedAt,
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Members retrieved",
+		"data":    data,
+	})
+}
+
+// UpdateMember changes a member's role. Owners manage their existing team
+// (new people join by invitation); admins may also add members directly.
+func UpdateMember(c *fiber.Ctx) error {
+	companyID, userID, ok := memberParams(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company or user ID",
+		})
+	}
+
+	type Req struct {
+		Role string `json:"role"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.Role == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Role is required",
+		})
+	}
+
+	if ok, err := membership.Require(c, companyID, membership.TeamManagers); !ok {
+		return err
+	}
+
+	create := membership.IsAdmin(c)
+	if create {
+		if err := database.DB.WithContext(c.UserContext()).First(&models.User{}, userID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "User not found",
+			})
+		}
+	}
+
+	member, err := membership.SetRole(c.UserContext(), companyID, userID, req.Role, create)
+	if err != nil {
+		return memberError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Member updated",
+		"data":    member,
+	})
+}
+
+// RemoveMember takes a user out of the company. Owners remove anyone,
+// members may leave on their own.
+func RemoveMember(c *fiber.Ctx) error {
+	companyID, userID, ok := memberParams(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid company or user ID",
+		})
+	}
+
+	if self, _ := c.Locals("user_id").(uint); self != userID {
+		if ok, err := membership.Require(c, companyID, membership.TeamManagers); !ok {
+			return err
+		}
+	}
+
+	if err := membership.Remove(c.UserContext(), companyID, userID); err != nil {
+		return memberError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Member removed",
+	})
+}
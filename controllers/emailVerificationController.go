@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/mailer"
+	"Auth/models"
+	"Auth/utils"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// emailVerificationTTL reads EMAIL_VERIFICATION_TTL_HOURS (default 24)
+func emailVerificationTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EMAIL_VERIFICATION_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// emailLinkBase is where links for emailed action tokens point. With APP_URL
+// set the link opens the frontend, which calls the API; otherwise it points
+// straight at the API endpoint under API_URL, on the board (/t/<slug>/) the
+// request was made for. Links are never built from the request's Host
+// header, which would let a caller send someone else's token to their own
+// server.
+func emailLinkBase(c *fiber.Ctx, frontendPath, apiPath string) (string, error) {
+	if appURL, err := utils.AppURL(); err == nil {
+		return appURL + frontendPath, nil
+	}
+	apiURL, err := utils.APIURL()
+	if err != nil {
+		return "", err
+	}
+	if slug, ok := c.Locals("tenant").(string); ok && strings.HasPrefix(c.OriginalURL(), "/t/"+slug+"/") {
+		apiPath = "/t/" + slug + apiPath
+	}
+	return apiURL + apiPath, nil
+}
+
+// emailLink builds the link for an emailed action token, see emailLinkBase
+func emailLink(c *fiber.Ctx, frontendPath, apiPath, token string) (string, error) {
+	base, err := emailLinkBase(c, frontendPath, apiPath)
+	if err != nil {
+		return "", err
+	}
+	return base + "?token=" + url.QueryEscape(token), nil
+}
+
+// sendVerificationEmail mails a signed link bound to the user's current
+// address, so changing the email invalidates links sent for the old one
+func sendVerificationEmail(c *fiber.Ctx, user models.User) error {
+	ttl := emailVerificationTTL()
+	token, err := utils.GenerateActionToken(utils.PurposeEmailVerify, user.ID, user.Email, ttl, nil)
+	if err != nil {
+		return err
+	}
+
+	link, err := emailLink(c, "/verify-email", "/api/auth/verify-email", token)
+	if err != nil {
+		return err
+	}
+	return mailer.Get().Send(c.UserContext(), mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, ignore this email.\n",
+			user.Username, link, int(ttl.Hours())),
+	})
+}
+
+// syncEmailVerified trusts the identity provider when it already verified
+// the address (e.g. Google sign-in), so those users are not asked again
+func syncEmailVerified(ctx context.Context, user *models.User, record *identity.UserRecord) {
+	if user.EmailVerifiedAt != nil || !record.EmailVerified ||
+		!strings.EqualFold(record.Email, user.Email) {
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.WithContext(ctx).Model(user).Update("email_verified_at", now).Error; err != nil {
+		log.Printf("⚠️  Email verification sync failed for user %d: %v", user.ID, err)
+		return
+	}
+	user.EmailVerifiedAt = &now
+}
+
+// VerifyEmail confirms an address with the token from the verification email.
+// Accepts GET ?token= (the link itself) or POST {"token": ...}.
+func VerifyEmail(c *fiber.Ctx) error {
+	type Req struct {
+		Token string `json:"token"`
+	}
+
+	var req Req
+	if c.Method() == fiber.MethodPost {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid input",
+			})
+		}
+	} else {
+		req.Token = c.Query("token")
+	}
+
+	if req.Token == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Token is required",
+		})
+	}
+
+	claims, err := utils.ValidateActionToken(req.Token, utils.PurposeEmailVerify)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired verification link",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, claims.UserID).Error; err != nil ||
+		!strings.EqualFold(user.Email, claims.Email) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired verification link",
+		})
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := database.DB.WithContext(c.UserContext()).Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to verify email",
+			})
+		}
+		user.EmailVerifiedAt = &now
+
+		// keep the identity provider in line; our column stays the source of truth
+		verified := true
+		if _, err := identity.Get().UpdateUser(context.Background(), user.FirebaseUID,
+			identity.UserToUpdate{EmailVerified: &verified}); err != nil {
+			log.Printf("⚠️  Identity provider email_verified update failed for user %d: %v", user.ID, err)
+		}
+		audit.Success(c, audit.ActionEmailVerify, user.ID, map[string]interface{}{"email": user.Email})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Email verified",
+		"data": fiber.Map{
+			"email":             user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+		},
+	})
+}
+
+// ResendVerificationEmail sends a fresh verification link to the current user
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "Email already verified",
+		})
+	}
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		log.Printf("⚠️  Verification email to user %d failed: %v", user.ID, err)
+		return c.Status(502).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to send verification email",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Verification email sent",
+	})
+}
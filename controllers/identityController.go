@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"Auth/accounts"
+	"Auth/audit"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/models"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// identityError maps accounts errors to a response
+func identityError(c *fiber.Ctx, err error) error {
+	status, message := 500, "Failed to update sign-in methods"
+	switch {
+	case errors.Is(err, accounts.ErrIdentityNotFound):
+		status, message = 404, "Sign-in method not found"
+	case errors.Is(err, accounts.ErrIdentityLinked):
+		status, message = 409, "This sign-in method is linked to another account"
+	case errors.Is(err, accounts.ErrLastIdentity):
+		status, message = 409, "You cannot unlink your only sign-in method"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
+// currentUserIdentities lists the sign-in methods of a user, oldest first
+func currentUserIdentities(c *fiber.Ctx, userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := database.DB.WithContext(c.UserContext()).
+		Where("user_id = ?", userID).
+		Order("linked_at, id").
+		Find(&identities).Error
+	return identities, err
+}
+
+// ListIdentities returns the sign-in methods linked to the current user
+func ListIdentities(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	identities, err := currentUserIdentities(c, userID)
+	if err != nil {
+		return identityError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Sign-in methods retrieved",
+		"data":    identities,
+	})
+}
+
+// LinkIdentity links the sign-in methods of another account to the current
+// user. The client signs in with the method to link (e.g. Google) and sends
+// the resulting ID token, which proves the account is theirs.
+func LinkIdentity(c *fiber.Ctx) error {
+	type Req struct {
+		IdToken string `json:"id_token"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.IdToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Firebase token is required",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	idp := identity.Get()
+	token, err := idp.VerifyIDToken(c.UserContext(), req.IdToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired Firebase token",
+		})
+	}
+	record, err := idp.GetUser(c.UserContext(), token.UID)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get user from Firebase",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	if err := accounts.Link(c.UserContext(), &user, record); err != nil {
+		if errors.Is(err, accounts.ErrIdentityLinked) {
+			audit.Failure(c, audit.ActionIdentityLink, user.ID, "identity_linked", map[string]interface{}{
+				"provider": accounts.SignInProvider(token, record),
+			})
+		}
+		return identityError(c, err)
+	}
+	audit.Success(c, audit.ActionIdentityLink, user.ID, map[string]interface{}{
+		"provider": accounts.SignInProvider(token, record),
+	})
+
+	identities, err := currentUserIdentities(c, userID)
+	if err != nil {
+		return identityError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Sign-in method linked",
+		"data":    identities,
+	})
+}
+
+// UnlinkIdentity removes a sign-in method of the current user; the last one
+// is kept
+func UnlinkIdentity(c *fiber.Ctx) error {
+	identityID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sign-in method ID",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	if err := accounts.Unlink(c.UserContext(), &user, uint(identityID)); err != nil {
+		return identityError(c, err)
+	}
+	audit.Success(c, audit.ActionIdentityUnlink, user.ID, map[string]interface{}{"identity_id": identityID})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Sign-in method unlinked",
+	})
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/models"
+	"Auth/roles"
+	"Auth/tenancy"
+	"Auth/utils"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// impersonationTTL reads IMPERSONATION_TTL_MINUTES (default 15, at most 60)
+func impersonationTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("IMPERSONATION_TTL_MINUTES"))
+	if err != nil || minutes <= 0 || minutes > 60 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// impersonationsOfTenant limits impersonation queries to users of the
+// request's board; the impersonation tables themselves are not scoped
+func impersonationsOfTenant(c *fiber.Ctx) *gorm.DB {
+	tenantID, _ := tenancy.FromContext(c.UserContext())
+	return database.DB.WithContext(c.UserContext()).Model(&models.Impersonation{}).
+		Joins("JOIN users ON users.id = impersonations.user_id AND users.tenant_id = ?", tenantID)
+}
+
+// StartImpersonation issues a short-lived access token for acting as a user
+// (admin only). Every request made with it is recorded.
+func StartImpersonation(c *fiber.Ctx) error {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A reason (e.g. the support ticket) is required",
+		})
+	}
+
+	targetID, err := c.ParamsInt("userId")
+	if err != nil || targetID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	actorID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+	if actorID == uint(targetID) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "You cannot impersonate yourself",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles.Permissions").First(&user, targetID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	// acting as another admin would hand out their privileges
+	for _, role := range user.Roles {
+		for _, permission := range role.Permissions {
+			if permission.Name == models.PermUserAdmin {
+				audit.Failure(c, audit.ActionImpersonationStart, user.ID, "target_is_admin", nil)
+				return c.Status(403).JSON(fiber.Map{
+					"success": false,
+					"message": "Admins cannot be impersonated",
+				})
+			}
+		}
+	}
+
+	ttl := impersonationTTL()
+	token, jti, err := utils.GenerateImpersonationJWT(utils.ImpersonatedUser{
+		UserID:      user.ID,
+		Email:       user.Email,
+		FirebaseUID: user.FirebaseUID,
+		Role:        roles.Primary(user.Roles),
+		TenantID:    user.TenantID,
+	}, actorID, ttl)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	impersonation := models.Impersonation{
+		ActorID:   actorID,
+		UserID:    user.ID,
+		TokenID:   jti,
+		Reason:    strings.TrimSpace(req.Reason),
+		IP:        c.IP(),
+		ExpiresAt: token.ExpiresAt,
+	}
+	if err := database.DB.WithContext(c.UserContext()).Create(&impersonation).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	log.Printf("🕵️  Admin %d started impersonating user %d (impersonation %d): %s",
+		actorID, user.ID, impersonation.ID, impersonation.Reason)
+	audit.Success(c, audit.ActionImpersonationStart, user.ID, map[string]interface{}{
+		"impersonation_id": impersonation.ID,
+		"reason":           impersonation.Reason,
+		"expires_at":       token.ExpiresAt,
+	})
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "Impersonation started",
+		"data": fiber.Map{
+			"impersonation_id": impersonation.ID,
+			"user_id":          user.ID,
+			"access_token":     token.Token,
+			"token_type":       "Bearer",
+			"expires_in":       token.ExpiresIn,
+			"expires_at":       token.ExpiresAt,
+		},
+	})
+}
+
+// StopImpersonation ends the impersonation of the presented token before it
+// expires
+func StopImpersonation(c *fiber.Ctx) error {
+	impersonation, ok := c.Locals("impersonation").(*models.Impersonation)
+	claims, hasClaims := c.Locals("jwt_claims").(*utils.JWTClaims)
+	if !ok || !hasClaims {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Not an impersonation token",
+		})
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Model(impersonation).
+		Update("ended_at", time.Now()).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if err := revokeAccessToken(claims); err != nil {
+		log.Printf("⚠️  Impersonation token %d not denylisted: %v", impersonation.ID, err)
+	}
+	audit.Success(c, audit.ActionImpersonationStop, impersonation.UserID, map[string]interface{}{
+		"impersonation_id": impersonation.ID,
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Impersonation stopped",
+	})
+}
+
+// ListImpersonations returns who impersonated whom, newest first, with the
+// number of requests made (admin only)
+func ListImpersonations(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := impersonationsOfTenant(c)
+	for _, filter := range []string{"user_id", "actor_id"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid " + filter,
+			})
+		}
+		query = query.Where("impersonations."+filter+" = ?", id)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	type impersonationRow struct {
+		models.Impersonation
+		Requests int64 `json:"requests"`
+	}
+	var rows []impersonationRow
+	if err := query.
+		Select("impersonations.*, (SELECT COUNT(*) FROM impersonation_requests r WHERE r.impersonation_id = impersonations.id) AS requests").
+		Order("impersonations.id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Impersonations retrieved",
+		"data":    rows,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ListImpersonatedRequests returns the requests made during one
+// impersonation, oldest first (admin only)
+func ListImpersonatedRequests(c *fiber.Ctx) error {
+	var impersonation models.Impersonation
+	if err := impersonationsOfTenant(c).
+		Where("impersonations.id = ?", c.Params("id")).
+		First(&impersonation).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Impersonation not found",
+		})
+	}
+
+	var requests []models.ImpersonationRequest
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("impersonation_id = ?", impersonation.ID).
+		Order("id").
+		Find(&requests).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Impersonated requests retrieved",
+		"data": fiber.Map{
+			"impersonation": impersonation,
+			"requests":      requests,
+		},
+	})
+}
@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"Auth/database"
+	"Auth/membership"
 	"Auth/models"
 	presenters "Auth/presenter"
 	"strconv"
@@ -89,16 +90,21 @@ func CreateJob(c *fiber.Ctx) error {
 
 	// Check if company exists
 	var company models.Company
-	if err := database.DB.First(&company, req.CompanyID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&company, req.CompanyID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Company not found",
 		})
 	}
 
+	// Only the company's team posts its jobs
+	if ok, err := membership.Require(c, company.ID, membership.JobEditors); !ok {
+		return err
+	}
+
 	// Check if job type exists
 	var jobType models.JobType
-	if err := database.DB.First(&jobType, req.JobTypeID).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&jobType, req.JobTypeID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Job type not found",
@@ -117,7 +123,7 @@ func CreateJob(c *fiber.Ctx) error {
 		CompanyID:   req.CompanyID,
 	}
 
-	if err := database.DB.Create(&job).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&job).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to save job",
@@ -125,7 +131,7 @@ func CreateJob(c *fiber.Ctx) error {
 	}
 
 	// Reload job with JobType and Company using Joins
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Joins("JobType").
 		Joins("Company").
 		First(&job, job.ID).Error; err != nil {
@@ -152,13 +158,17 @@ func UpdateJob(c *fiber.Ctx) error {
 
 	// Find existing job
 	var job models.Job
-	if err := database.DB.First(&job, uint(jobID)).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&job, uint(jobID)).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Job not found",
 		})
 	}
 
+	if ok, err := membership.Require(c, job.CompanyID, membership.JobEditors); !ok {
+		return err
+	}
+
 	// Parse JSON body
 	type Req struct {
 		Name        string `json:"name"`
@@ -214,23 +224,27 @@ func UpdateJob(c *fiber.Ctx) error {
 	}
 
 	// Update relations if provided
-	if req.CompanyID > 0 {
+	if req.CompanyID > 0 && req.CompanyID != job.CompanyID {
 		var company models.Company
-		if err := database.DB.First(&company, req.CompanyID).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).First(&company, req.CompanyID).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"success": false, "message": "Company not found"})
 		}
+		// moving a job needs rights in both companies
+		if ok, err := membership.Require(c, company.ID, membership.JobEditors); !ok {
+			return err
+		}
 		job.CompanyID = req.CompanyID
 	}
 	if req.JobTypeID > 0 {
 		var jobType models.JobType
-		if err := database.DB.First(&jobType, req.JobTypeID).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).First(&jobType, req.JobTypeID).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"success": false, "message": "Job type not found"})
 		}
 		job.JobTypeID = req.JobTypeID
 	}
 
 	// Save changes
-	if err := database.DB.Save(&job).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Save(&job).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update job"})
 	}
 
@@ -250,15 +264,19 @@ func DeleteJob(c *fiber.Ctx) error {
 
 	// Find job
 	var job models.Job
-	if err := database.DB.First(&job, uint(jobID)).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&job, uint(jobID)).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
 			"message": "Job not found",
 		})
 	}
 
+	if ok, err := membership.Require(c, job.CompanyID, membership.JobEditors); !ok {
+		return err
+	}
+
 	// Delete job
-	if err := database.DB.Delete(&job).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Delete(&job).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to delete job",
@@ -291,7 +309,7 @@ func GetAllJobs(c *fiber.Ctx) error {
 
 	// Get total count
 	var totalItems int64
-	if err := database.DB.Model(&models.Job{}).Count(&totalItems).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.Job{}).Count(&totalItems).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to count jobs",
@@ -299,7 +317,7 @@ func GetAllJobs(c *fiber.Ctx) error {
 	}
 
 	// Get paginated jobs (with relations if needed)
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Preload("Company").
 		Preload("JobType").
 		Order("created_at DESC").
@@ -338,7 +356,7 @@ func GetJobByID(c *fiber.Ctx) error {
 
 	// Find job with relations
 	var job models.Job
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Preload("Company").
 		Preload("JobType").
 		First(&job, uint(jobID)).Error; err != nil {
@@ -358,7 +376,7 @@ func GetJobByID(c *fiber.Ctx) error {
 func GetJobs(c *fiber.Ctx) error {
 	var reports []models.Job
 
-	err := database.DB.Table("jobs").
+	err := database.DB.WithContext(c.UserContext()).Table("jobs").
 		Select(`jobs.id as job_id,
                 jobs.name as job_name,
                 companies.name as company_name,
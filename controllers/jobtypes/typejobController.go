@@ -33,7 +33,7 @@ func GetallTypeJob(c *fiber.Ctx) error {
 
 	// Get total count
 	var totalItems int64
-	if err := database.DB.Model(&models.JobType{}).Where("status = ?", 1).Count(&totalItems).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.JobType{}).Where("status = ?", 1).Count(&totalItems).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "ຜີດພາດໃນການດຶງຂໍ້ມູນ",
@@ -41,7 +41,7 @@ func GetallTypeJob(c *fiber.Ctx) error {
 	}
 
 	// Get paginated data
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Where("status = ?", 1).
 		Order("created_at DESC").
 		Limit(limit).
@@ -84,7 +84,7 @@ func GetTypeJobByID(c *fiber.Ctx) error {
 	var jobType models.JobType
 
 	// query from database
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Where("id = ? AND status = ?", id, 1).
 		First(&jobType).Error; err != nil {
 
@@ -135,7 +135,7 @@ func CreateTypeJob(c *fiber.Ctx) error {
 		Status: 1,
 	}
 
-	if err := database.DB.Create(&jobType).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&jobType).Error; err != nil {
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
 			return c.Status(400).JSON(fiber.Map{
 				"success": false,
@@ -174,7 +174,7 @@ func UpdateTypeJob(c *fiber.Ctx) error {
 
 	// Check if exists
 	var jobType models.JobType
-	if err := database.DB.Where("id = ? AND status = ?", idInt, models.StatusActive).First(&jobType).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Where("id = ? AND status = ?", idInt, models.StatusActive).First(&jobType).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(404).JSON(presenters.ResponseError(c, 404, "ບໍ່ພົບຂໍ້ມູນປະເພດນີ້"))
 		}
@@ -188,7 +188,7 @@ func UpdateTypeJob(c *fiber.Ctx) error {
 
 	// Update
 	jobType.Name = req.Name
-	if err := database.DB.Save(&jobType).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Save(&jobType).Error; err != nil {
 		// Use DB-specific error handling instead of string matching
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return presenters.ResponseError(c, 400, "ຊື່ປະເພດນີ້ ໄດ້ຖືກໃຊ້ແລ້ວ")
@@ -212,7 +212,7 @@ func DeleteTypeJob(c *fiber.Ctx) error {
 
 	// 2. Find record including deleted ones
 	var jobType models.JobType
-	if err := database.DB.Unscoped().First(&jobType, idInt).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Unscoped().First(&jobType, idInt).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(
 				presenters.ResponseError(c, fiber.StatusNotFound, "ບໍ່ພົບຂໍ້ມູນປະເພດນີ້"),
@@ -231,7 +231,7 @@ func DeleteTypeJob(c *fiber.Ctx) error {
 	}
 	// 5. Soft delete
 	jobType.Status = 0
-	if err := database.DB.Delete(&jobType).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Delete(&jobType).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(
 			presenters.ResponseError(c, fiber.StatusInternalServerError, "ຜີດພາດໃນການລົບຂໍ້ມູນ"),
 		)
@@ -239,7 +239,7 @@ func DeleteTypeJob(c *fiber.Ctx) error {
 
 	// 6. Reload to get DeletedAt timestamp
 	jobType.Status = 0
-	if err := database.DB.Unscoped().First(&jobType, idInt).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Unscoped().First(&jobType, idInt).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(
 			presenters.ResponseError(c, fiber.StatusInternalServerError, "ຜີດພາດໃນການດຶງຂໍ້ມູນຫຼັງການລົບ"),
 		)
@@ -265,7 +265,7 @@ func Search(c *fiber.Ctx) error {
 
 	// 2. Query database (exclude soft-deleted records)
 	var jobTypes []models.JobType
-	if err := database.DB.
+	if err := database.DB.WithContext(c.UserContext()).
 		Where("LOWER(name) LIKE LOWER(?) AND deleted_at IS NULL", "%"+name+"%").
 		Find(&jobTypes).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"Auth/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS publishes the public keys other services use to verify our tokens
+func JWKS(c *fiber.Ctx) error {
+	set, err := utils.GetJWKS()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Signing keys unavailable",
+		})
+	}
+
+	// verifiers cache the set; rotation keeps the old key published for a while
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(200).JSON(set)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/models"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalSignIn stands in for the Firebase client SDK when IDENTITY_PROVIDER=local:
+// it exchanges email/password for an ID token accepted by /firebase-login and
+// the protected routes. Only registered when the local provider is active.
+func LocalSignIn(c *fiber.Ctx) error {
+	type Req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	local, ok := identity.Get().(*identity.LocalProvider)
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Local identity provider is not enabled",
+		})
+	}
+
+	// audited like /login; the target is the account of the email, if any
+	var user models.User
+	database.DB.WithContext(c.UserContext()).Select("id").Where("email = ?", req.Email).First(&user)
+
+	idToken, err := local.SignInWithPassword(c.UserContext(), req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, identity.ErrInvalidCredentials) || errors.Is(err, identity.ErrUserDisabled) {
+			reason := "invalid_credentials"
+			if errors.Is(err, identity.ErrUserDisabled) {
+				reason = "user_disabled"
+			}
+			audit.Failure(c, audit.ActionLogin, user.ID, reason, map[string]interface{}{
+				"method": "local",
+				"email":  req.Email,
+			})
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to sign in",
+		})
+	}
+
+	// not a login yet: the ID token still has to be exchanged at /login,
+	// which records it
+	audit.Success(c, audit.ActionIdPSignIn, user.ID, map[string]interface{}{
+		"method": "local",
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id_token": idToken,
+		},
+	})
+}
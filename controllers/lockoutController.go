@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/lockout"
+	"Auth/models"
+	"Auth/tenancy"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UnlockAccount lifts a login lockout or backoff for a user (admin only)
+func UnlockAccount(c *fiber.Ctx) error {
+	targetID, err := strconv.ParseUint(c.Params("userId"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	actorID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	// admins only manage users of their own board
+	if err := database.DB.WithContext(c.UserContext()).Select("id").First(&models.User{}, targetID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	err = lockout.Unlock(uint(targetID), actorID, c.IP())
+	if errors.Is(err, lockout.ErrNotLocked) {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Account is not locked",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to unlock account",
+		})
+	}
+	audit.Success(c, audit.ActionAccountUnlock, uint(targetID), nil)
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Account unlocked",
+	})
+}
+
+// ListLockoutEvents shows why accounts were slowed down or locked, newest
+// first. Filters: user_id, event; paging: page, limit (max 100).
+// Admins only see events of their board's users; IP blocks and unknown
+// emails belong to no board.
+func ListLockoutEvents(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	// lockout events carry no tenant, their users do. The join is not
+	// rewritten by the tenancy callbacks, so it carries the tenant itself
+	// and, like them, fails closed without one.
+	tenantID, ok := tenancy.FromContext(c.UserContext())
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	query := database.DB.WithContext(c.UserContext()).Model(&models.LockoutEvent{}).
+		Joins("JOIN users ON users.id = lockout_events.user_id AND users.tenant_id = ?", tenantID)
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid user ID",
+			})
+		}
+		query = query.Where("lockout_events.user_id = ?", id)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("lockout_events.event = ?", event)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	var events []models.LockoutEvent
+	if err := query.Order("lockout_events.id DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Lockout events retrieved",
+		"data":    events,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"Auth/tenancy"
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestListLockoutEventsIsTenantScoped(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenant     uint // 0 for none
+		wantStatus int
+	}{
+		{"board admin", 1, 200},
+		{"no tenant", 0, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t)
+			fake.On(`FROM "lockout_events"`, "count(").Rows([]string{"count"}, []driver.Value{int64(0)})
+
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tt.tenant != 0 {
+					c.SetUserContext(tenancy.WithTenant(c.UserContext(), tt.tenant))
+				}
+				return c.Next()
+			}, ListLockoutEvents)
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/?event=locked", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			// events carry no tenant, the join on their users does
+			join := "JOIN users ON users.id = lockout_events.user_id AND users.tenant_id = $"
+			for _, query := range []string{"count(", `"lockout_events"."id"`} {
+				if ran := fake.Ran(query, join); ran != (tt.tenant != 0) {
+					t.Errorf("tenant-scoped %s query ran = %v, want %v", query, ran, tt.tenant != 0)
+				}
+			}
+			if tt.tenant == 0 && fake.Ran("lockout_events") {
+				t.Error("events were read without a tenant")
+			}
+		})
+	}
+}
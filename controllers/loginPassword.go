@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/middleware"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// LoginWithPassword authenticates against the password hash stored at
+// registration, without calling the identity provider at all
+func LoginWithPassword(c *fiber.Ctx) error {
+	type Req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || req.Password == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Email and password are required",
+		})
+	}
+
+	// 1. Find user; the same error is returned for every failure below
+	// so the endpoint cannot be used to discover registered emails
+	var user models.User
+	err := database.DB.WithContext(c.UserContext()).
+		Preload("Roles").
+		Where("email = ?", req.Email).
+		First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	// 2. Verify password (hash a dummy when there is nothing to compare,
+	// keeping response time independent of whether the account exists)
+	encoded := user.Password
+	if encoded == "" {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = utils.HashPassword("dummy-password")
+		})
+		encoded = dummyPasswordHash
+	}
+
+	ok, needsRehash, err := utils.VerifyPassword(encoded, req.Password)
+	if err != nil || !ok || user.ID == 0 || user.Password == "" {
+		audit.Failure(c, audit.ActionLogin, user.ID, "invalid_credentials", map[string]interface{}{
+			"method": "password",
+			"email":  req.Email,
+		})
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid email or password",
+		})
+	}
+
+	// 3. Transparently upgrade hashes made with an old algorithm or cost
+	if needsRehash {
+		if hash, err := utils.HashPassword(req.Password); err == nil {
+			if err := database.DB.WithContext(c.UserContext()).Model(&user).Update("password", hash).Error; err != nil {
+				log.Printf("⚠️  Password rehash failed for user %d: %v", user.ID, err)
+			}
+		}
+	}
+
+	// 4. Users with a second factor get a challenge instead of tokens
+	if required, err := middleware.MFAEnrolled(c, user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	} else if required {
+		return mfaChallengeResponse(c, user, "password")
+	}
+
+	// 5. Issue tokens
+	pair, err := issueTokenPair(c, user, "password")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Login successful",
+		"data":    loginResponse(user, pair),
+	})
+}
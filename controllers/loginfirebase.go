@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"Auth/accounts"
+	"Auth/audit"
 	"Auth/database"
 	"Auth/firebase"
+	"Auth/identity"
+	"Auth/middleware"
 	"Auth/models"
-	"Auth/utils"
+	"Auth/tenancy"
 	"context"
+	"errors"
+	"log"
 	"strings"
 
-	"firebase.google.com/go/v4/auth"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
@@ -36,12 +41,13 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get Firebase Auth client instance
-	authClient := firebase.GetAuthClient()
+	// Get the identity provider (Firebase or local)
+	idp := identity.Get()
 
-	// Verify the Firebase ID token to ensure it's valid and not expired
-	token, err := authClient.VerifyIDToken(context.Background(), req.IdToken)
+	// Verify the ID token to ensure it's valid, not expired and not revoked
+	token, err := idp.VerifyIDToken(context.Background(), req.IdToken)
 	if err != nil {
+		audit.Failure(c, audit.ActionLogin, 0, "invalid_token", map[string]interface{}{"method": "firebase"})
 		return c.Status(401).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid or expired Firebase token",
@@ -50,7 +56,7 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 	}
 
 	// Retrieve the complete Firebase user profile using the UID from the verified token
-	firebaseUser, err := authClient.GetUser(context.Background(), token.UID)
+	firebaseUser, err := idp.GetUser(context.Background(), token.UID)
 	if err != nil {
 		return c.Status(401).JSON(fiber.Map{
 			"success": false,
@@ -58,27 +64,44 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 		})
 	}
 
-	// Try to find an existing user in the database by Firebase UID
-	// Preload the user's roles to avoid N+1 query issues
-	var user models.User
-	err = database.DB.
-		Preload("Roles").
-		Where("firebase_uid = ?", token.UID).
-		First(&user).Error
-
-	// Handle user not found scenario
-	if err != nil {
-		// If error is something other than "not found", it's a database issue
-		if err != gorm.ErrRecordNotFound {
-			return c.Status(500).JSON(fiber.Map{
-				"success": false,
-				"message": "Database error",
-			})
-		}
+	// The sign-in method used for this login (google, password, ...)
+	provider := accounts.SignInProvider(token, firebaseUser)
 
+	// Find the user by Firebase UID, a linked account or a verified email
+	// Roles are preloaded to avoid N+1 query issues
+	var user models.User
+	existing, err := accounts.Resolve(c.UserContext(), firebaseUser)
+	switch {
+	case err == nil:
+		user = *existing
+	case errors.Is(err, accounts.ErrEmailTaken):
+		// never create a second account for the address
+		audit.Failure(c, audit.ActionLogin, 0, "email_taken", map[string]interface{}{
+			"method": provider,
+			"email":  firebaseUser.Email,
+		})
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "An account with this email already exists. Sign in to it and link this sign-in method",
+		})
+	case errors.Is(err, accounts.ErrIdentityLinked):
+		audit.Failure(c, audit.ActionLogin, 0, "identity_linked", map[string]interface{}{
+			"method": provider,
+			"email":  firebaseUser.Email,
+		})
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "This sign-in method is linked to another account",
+		})
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	default:
 		// Load the default "user" role from the database
 		var role models.Role
-		if err := database.DB.Where("name = ?", "user").First(&role).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).Where("name = ?", "user").First(&role).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"success": false,
 				"message": "Role not found",
@@ -87,33 +110,42 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 
 		// Generate a unique username based on Firebase user info
 		// This prevents duplicate username errors
-		username := generateUniqueUsername(database.DB, firebaseUser, token.UID)
+		username := generateUniqueUsername(database.DB.WithContext(c.UserContext()), firebaseUser, token.UID)
 
 		// Create a new user record with data from Firebase
 		user = models.User{
-			FirebaseUID: token.UID,                 // Firebase unique identifier
-			Email:       firebaseUser.Email,        // User's email address
-			Username:    username,                  // Generated unique username
-			Provider:    getProvider(firebaseUser), // Authentication provider (google, facebook, etc.)
-			Roles:       []models.Role{role},       // Assign default "user" role
+			FirebaseUID: token.UID,           // Firebase unique identifier
+			Email:       firebaseUser.Email,  // User's email address
+			Username:    username,            // Generated unique username
+			Provider:    provider,            // Authentication provider (google, facebook, etc.)
+			Roles:       []models.Role{role}, // Assign default "user" role
 		}
 
 		// Insert the new user into the database
-		if err := database.DB.Create(&user).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).Create(&user).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"success": false,
 				"message": err.Error(),
 			})
 		}
+		audit.Success(c, audit.ActionRegister, user.ID, map[string]interface{}{"method": provider})
 	}
 
+	// Record the account's sign-in methods for the user
+	if err := accounts.Sync(c.UserContext(), user.ID, firebaseUser); err != nil {
+		log.Printf("⚠️  Identity sync failed for user %d: %v", user.ID, err)
+	}
+
+	// Adopt the provider's email verification (e.g. Google accounts)
+	syncEmailVerified(c.UserContext(), &user, firebaseUser)
+
 	// Generate custom claims for Firebase token
 	// This includes user_id and roles that will be embedded in future Firebase tokens
 	userClaims := firebase.GenerateUserClaims(user)
 
 	// Update Firebase custom claims so they appear in the user's ID token
 	// This allows the client to verify user permissions without additional API calls
-	err = authClient.SetCustomUserClaims(context.Background(), user.FirebaseUID, userClaims)
+	err = idp.SetCustomUserClaims(context.Background(), user.FirebaseUID, userClaims)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -121,9 +153,19 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 		})
 	}
 
+	// Users with a second factor get a challenge instead of tokens
+	if required, err := middleware.MFAEnrolled(c, user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	} else if required {
+		return mfaChallengeResponse(c, user, provider)
+	}
+
 	// Generate our application's JWT token for API authentication
-	// This token will be used for subsequent API requests
-	jwtToken, err := utils.GenerateJWTWithExpiry(user.ID, user.Email, user.FirebaseUID, "user")
+	// together with a refresh token that starts a new session
+	pair, err := issueTokenPair(c, user, provider)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -135,43 +177,11 @@ func LoginWithFirebase(c *fiber.Ctx) error {
 	return c.Status(200).JSON(fiber.Map{
 		"success": true,
 		"message": "Login successful",
-		"data": fiber.Map{
-			"token":      jwtToken,  // JWT token for API authentication
-			//"expires_in": expiresIn, // Token expiration time in seconds (86400 = 24 hours)
-			"user": fiber.Map{
-				"id":       user.ID,                           // Database user ID
-				"uid":      user.FirebaseUID,                  // Firebase unique identifier
-				"email":    user.Email,                        // User's email address
-				"provider": user.Provider,                     // Authentication provider
-				"roles":    firebase.GetRoleNames(user.Roles), // Array of role names (e.g., ["user", "admin"])
-			},
-		},
+		"data":    loginResponse(user, pair),
 	})
 }
 
-// getProvider and Returns
-func getProvider(user *auth.UserRecord) string {
-	// Check if user has any provider information
-	if len(user.ProviderUserInfo) > 0 {
-		// Get the first provider ID (primary authentication method)
-		providerID := user.ProviderUserInfo[0].ProviderID
-
-		// Map Firebase provider IDs to friendly names
-		switch providerID {
-		case "google.com":
-			return "google"
-		case "facebook.com":
-			return "facebook"
-		case "apple.com":
-			return "apple"
-		default:
-			return providerID // Return raw provider ID if not matched
-		}
-	}
-	// If no provider info exists, assume email/password authentication
-	return "password"
-}
-func generateUniqueUsername(db *gorm.DB, user *auth.UserRecord, uid string) string {
+func generateUniqueUsername(db *gorm.DB, user *identity.UserRecord, uid string) string {
 	baseUsername := ""
 
 	// Priority 1: DisplayName
@@ -187,10 +197,10 @@ func generateUniqueUsername(db *gorm.DB, user *auth.UserRecord, uid string) stri
 		baseUsername = "user"
 	}
 
-	// Check if username exists
+	// Check if username exists (usernames are unique across tenants)
 	username := baseUsername
 	var existingUser models.User
-	err := db.Where("username = ?", username).First(&existingUser).Error
+	err := db.WithContext(tenancy.System(db.Statement.Context)).Where("username = ?", username).First(&existingUser).Error
 
 	// If username exists, add UID suffix
 	if err == nil {
@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/firebase"
+	"Auth/identity"
+	"Auth/mailer"
+	"Auth/middleware"
+	"Auth/models"
+	"Auth/tenancy"
+	"Auth/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// magicLinkDeviceCookie carries the device secret of a pending magic link
+// in browsers; other clients send it back as device_token
+const magicLinkDeviceCookie = "magic_link_device"
+
+// magicLinkTTL reads MAGIC_LINK_TTL_MINUTES (default 15)
+func magicLinkTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("MAGIC_LINK_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RequestMagicLink mails a single-use sign-in link; addresses without an
+// account get one when the link is used. The response is the same for every
+// address and carries the device secret the link is bound to, so the link
+// only works on the device that asked for it.
+func RequestMagicLink(c *fiber.Ctx) error {
+	type Req struct {
+		Email string `json:"email"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := validator.New().Var(email, "required,email"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A valid email is required",
+		})
+	}
+
+	// without a configured base URL no link is sent: one built from the
+	// request's Host would deliver the victim's click, together with the
+	// device token returned below, to whoever forged the header
+	linkBase, err := emailLinkBase(c, "/magic-link", "/api/auth/magic-link/verify")
+	if err != nil {
+		log.Printf("⚠️  Magic links unavailable: %v", err)
+		return c.Status(503).JSON(fiber.Map{
+			"success": false,
+			"message": "Sign-in links are not available",
+		})
+	}
+
+	device, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	// emails are unique across boards: an address of another board gets no link
+	var user models.User
+	err = database.DB.WithContext(c.UserContext()).Where("LOWER(email) = ?", email).First(&user).Error
+	deliver := err == nil
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var taken int64
+		err = database.DB.WithContext(tenancy.System(c.UserContext())).Model(&models.User{}).
+			Where("LOWER(email) = ?", email).
+			Count(&taken).Error
+		deliver = err == nil && taken == 0
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	ttl := magicLinkTTL()
+	if deliver {
+		nonce, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to generate token",
+			})
+		}
+
+		// a new link supersedes the pending ones of the address
+		now := time.Now()
+		link := models.MagicLink{
+			Email:      email,
+			TokenHash:  utils.HashToken(nonce),
+			DeviceHash: utils.HashToken(device),
+			IP:         c.IP(),
+			ExpiresAt:  now.Add(ttl),
+		}
+		if err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.MagicLink{}).
+				Where("email = ? AND used_at IS NULL", email).
+				Update("used_at", now).Error; err != nil {
+				return err
+			}
+			return tx.Create(&link).Error
+		}); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+
+		token, err := utils.GenerateActionToken(utils.PurposeMagicLink, user.ID, email, ttl, map[string]string{
+			"link_id": strconv.FormatUint(uint64(link.ID), 10),
+			"nonce":   nonce,
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to generate token",
+			})
+		}
+
+		// sent in the background, so the response time does not tell whether
+		// the address has an account
+		signInURL := linkBase + "?token=" + url.QueryEscape(token)
+		go func() {
+			if err := mailer.Get().Send(context.Background(), mailer.Message{
+				To:      email,
+				Subject: "Your sign-in link",
+				Text: fmt.Sprintf("Hi,\n\nOpen the link below to sign in:\n\n%s\n\n"+
+					"The link expires in %d minutes, can be used once and only works on the device "+
+					"you requested it from. If you did not ask for this, ignore this email.\n",
+					signInURL, int(ttl.Minutes())),
+			}); err != nil {
+				log.Printf("⚠️  Magic link email to %s failed: %v", email, err)
+			}
+		}()
+		audit.Requested(c, audit.ActionMagicLinkRequest, user.ID, map[string]interface{}{"email": email})
+	} else {
+		audit.Failure(c, audit.ActionMagicLinkRequest, 0, "other_tenant", map[string]interface{}{"email": email})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     magicLinkDeviceCookie,
+		Value:    device,
+		Expires:  time.Now().Add(ttl),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "If this address can sign in here, a sign-in link has been sent",
+		"data": fiber.Map{
+			"device_token": device,
+			"expires_in":   int64(ttl.Seconds()),
+		},
+	})
+}
+
+// errInvalidMagicLink covers unknown, forged, expired and used links alike
+var errInvalidMagicLink = errors.New("invalid or expired magic link")
+
+// consumeMagicLink checks the token and device secret and marks the link
+// used. The conditional update makes it single use, so a replayed link fails.
+func consumeMagicLink(tx *gorm.DB, token, device string) (*models.MagicLink, error) {
+	claims, err := utils.ValidateActionToken(token, utils.PurposeMagicLink)
+	if err != nil {
+		return nil, err
+	}
+
+	linkID, err := strconv.ParseUint(claims.Data["link_id"], 10, 64)
+	if err != nil {
+		return nil, utils.ErrInvalidClaims
+	}
+
+	var link models.MagicLink
+	if err := tx.First(&link, linkID).Error; err != nil {
+		return nil, err
+	}
+	if link.TokenHash != utils.HashToken(claims.Data["nonce"]) || link.Email != claims.Email ||
+		link.DeviceHash != utils.HashToken(device) {
+		return nil, utils.ErrInvalidClaims
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.MagicLink{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", link.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.ErrExpiredToken
+	}
+	return &link, nil
+}
+
+// magicLinkUser finds the user of the address, or creates one with an
+// identity-provider account of its own. Using the link proves the address.
+func magicLinkUser(c *fiber.Ctx, db *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	err := db.Where("LOWER(email) = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var role models.Role
+		if err := db.Where("name = ?", "user").First(&role).Error; err != nil {
+			return nil, err
+		}
+
+		idp := identity.Get()
+		record, err := idp.CreateUser(context.Background(), identity.UserToCreate{
+			Email:         email,
+			EmailVerified: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		user = models.User{
+			FirebaseUID:     record.UID,
+			Email:           email,
+			Username:        generateUniqueUsername(db, record, record.UID),
+			Provider:        "magic_link",
+			EmailVerifiedAt: &now,
+			Roles:           []models.Role{role},
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.User_Details{UserID: user.ID}).Error
+		}); err != nil {
+			idp.DeleteUser(context.Background(), record.UID)
+			return nil, err
+		}
+		log.Printf("✅ User %d created by magic link", user.ID)
+		audit.Success(c, audit.ActionRegister, user.ID, map[string]interface{}{"method": "magic_link"})
+	} else if err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := db.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	// roles are needed for the tokens
+	if err := db.Preload("Roles").First(&user, user.ID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// VerifyMagicLink signs in with a magic link and returns the same payload
+// as LoginWithFirebase. Accepts POST {"token", "device_token"} or GET
+// ?token= (the link itself) with the device cookie.
+func VerifyMagicLink(c *fiber.Ctx) error {
+	type Req struct {
+		Token       string `json:"token"`
+		DeviceToken string `json:"device_token"`
+	}
+
+	var req Req
+	if c.Method() == fiber.MethodGet {
+		req.Token = c.Query("token")
+	} else if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if req.DeviceToken == "" {
+		req.DeviceToken = c.Cookies(magicLinkDeviceCookie)
+	}
+	if req.Token == "" || req.DeviceToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Token and device are required. Open the link on the device you requested it from",
+		})
+	}
+
+	// the link is only used up once its user is resolved: the transaction
+	// rolls the consumption back on a 409 or 500, and the row lock holds a
+	// concurrent use of the same link until then
+	var user *models.User
+	err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		link, err := consumeMagicLink(tx, req.Token, req.DeviceToken)
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, utils.ErrExpiredToken) ||
+			errors.Is(err, utils.ErrInvalidToken) || errors.Is(err, utils.ErrInvalidClaims) {
+			return errInvalidMagicLink
+		}
+		if err != nil {
+			return err
+		}
+		user, err = magicLinkUser(c, tx, link.Email)
+		return err
+	})
+	if errors.Is(err, errInvalidMagicLink) {
+		// 401 so that guessing is throttled by the login guard
+		audit.Failure(c, audit.ActionLogin, 0, "invalid_magic_link", map[string]interface{}{"method": "magic_link"})
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired sign-in link. Request a new one on this device",
+		})
+	}
+	if errors.Is(err, identity.ErrEmailExists) {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "An account with this email already exists. Sign in with your usual method",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to sign in",
+		})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     magicLinkDeviceCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+
+	// keep the custom claims of the user's ID tokens in line, as LoginWithFirebase does
+	if err := identity.Get().SetCustomUserClaims(context.Background(), user.FirebaseUID, firebase.GenerateUserClaims(*user)); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to sync user claims to Firebase",
+		})
+	}
+
+	// Users with a second factor get a challenge instead of tokens
+	if required, err := middleware.MFAEnrolled(c, user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	} else if required {
+		return mfaChallengeResponse(c, *user, "magic_link")
+	}
+
+	pair, err := issueTokenPair(c, *user, "magic_link")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Login successful",
+		"data":    loginResponse(*user, pair),
+	})
+}
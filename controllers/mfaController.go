@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// mfaChallengeResponse is returned by login endpoints instead of tokens when
+// the user has MFA enabled. The challenge is exchanged at /auth/mfa/verify.
+func mfaChallengeResponse(c *fiber.Ctx, user models.User, provider string) error {
+	challenge, err := utils.GenerateActionToken(utils.PurposeMFA, user.ID, user.Email, mfaChallengeTTL,
+		map[string]string{"provider": provider})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate MFA challenge",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "MFA required",
+		"data": fiber.Map{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_in":   int64(mfaChallengeTTL.Seconds()),
+		},
+	})
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code
+func verifySecondFactor(userID uint, code string) (bool, error) {
+	var factor models.TOTPFactor
+	if err := database.DB.
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		First(&factor).Error; err != nil {
+		return false, err
+	}
+
+	if step, ok := utils.ValidateTOTP(factor.Secret, code, time.Now()); ok {
+		// consume the time step so the same code cannot be replayed
+		result := database.DB.Model(&models.TOTPFactor{}).
+			Where("id = ? AND last_used_step < ?", factor.ID, step).
+			Update("last_used_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL",
+			userID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// EnrollTOTP starts enrollment: returns a new secret and the otpauth:// URI
+// to render as a QR code. Logins are not affected until ConfirmTOTP.
+func EnrollTOTP(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+	email, _ := c.Locals("email").(string)
+
+	var factor models.TOTPFactor
+	err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", userID).First(&factor).Error
+	if err == nil && factor.ConfirmedAt != nil {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "Authenticator already enrolled",
+		})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate secret",
+		})
+	}
+
+	// restarting an unfinished enrollment replaces its secret
+	factor.UserID = userID
+	factor.Secret = secret
+	if err := database.DB.WithContext(c.UserContext()).Save(&factor).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save authenticator",
+		})
+	}
+
+	issuer := os.Getenv("API_NAME")
+	if issuer == "" {
+		issuer = "Auth"
+	}
+	account := email
+	if account == "" {
+		account = strconv.FormatUint(uint64(userID), 10)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Scan the QR code and confirm with a code",
+		"data": fiber.Map{
+			"secret":      secret,
+			"otpauth_uri": utils.TOTPURI(issuer, account, secret),
+		},
+	})
+}
+
+// ConfirmTOTP activates the authenticator with a first valid code and
+// returns the recovery codes. They are shown only this once.
+func ConfirmTOTP(c *fiber.Ctx) error {
+	type Req struct {
+		Code string `json:"code"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var factor models.TOTPFactor
+	if err := database.DB.WithContext(c.UserContext()).Where("user_id = ? AND confirmed_at IS NULL", userID).First(&factor).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "No pending authenticator enrollment",
+		})
+	}
+
+	step, valid := utils.ValidateTOTP(factor.Secret, req.Code, time.Now())
+	if !valid {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate recovery codes",
+		})
+	}
+
+	now := time.Now()
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&factor).Updates(map[string]interface{}{
+			"confirmed_at":   now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		// a new enrollment invalidates recovery codes of an old one
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range codes {
+			if err := tx.Create(&models.RecoveryCode{
+				UserID:   userID,
+				CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to confirm authenticator",
+		})
+	}
+	audit.Success(c, audit.ActionMFAEnroll, userID, map[string]interface{}{"factor": "totp"})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication enabled",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTOTP removes the authenticator; requires a current code or recovery code
+func DisableTOTP(c *fiber.Ctx) error {
+	type Req struct {
+		Code string `json:"code"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	valid, err := verifySecondFactor(userID, req.Code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !valid {
+		audit.Failure(c, audit.ActionMFADisable, userID, "invalid_code", nil)
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TOTPFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+	audit.Success(c, audit.ActionMFADisable, userID, map[string]interface{}{"factor": "totp"})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// VerifyMFA completes a login: exchanges the mfa_token challenge plus a valid
+// TOTP or recovery code for the real access/refresh tokens
+func VerifyMFA(c *fiber.Ctx) error {
+	type Req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+	if req.MFAToken == "" || req.Code == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "MFA token and code are required",
+		})
+	}
+
+	claims, err := utils.ValidateActionToken(req.MFAToken, utils.PurposeMFA)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	// the challenge is single use: once exchanged its jti is denylisted
+	challengeID := "mfa:" + claims.ID
+	var used int64
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.RevokedToken{}).
+		Where("jti = ?", challengeID).
+		Count(&used).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if used > 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	valid, err := verifySecondFactor(claims.UserID, req.Code)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !valid {
+		audit.Failure(c, audit.ActionLogin, claims.UserID, "invalid_mfa_code", map[string]interface{}{
+			"method": claims.Data["provider"],
+		})
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, claims.UserID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	// the unique jti makes a concurrent second exchange fail here
+	if err := database.DB.Create(&models.RevokedToken{
+		JTI:       challengeID,
+		UserID:    user.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	pair, err := issueTokenPair(c, user, claims.Data["provider"])
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Login successful",
+		"data":    loginResponse(user, pair),
+	})
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"Auth/utils"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql/driver"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// totpAt computes the RFC 6238 code of secret for time t, as an
+// authenticator app would
+func totpAt(t *testing.T, secret string, at time.Time) (string, int64) {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := at.Unix() / 30
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000), step
+}
+
+func TestVerifyMFA(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-hs256")
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, step := totpAt(t, secret, time.Now())
+	challenge, err := utils.GenerateActionToken(utils.PurposeMFA, 42, "ada@example.com", 5*time.Minute,
+		map[string]string{"provider": "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		code           string
+		challengeUsed  bool  // jti already denylisted
+		stepConsumed   int64 // rows the last_used_step update changes
+		recoveryUsed   int64 // rows the recovery code update changes
+		exchangeFails  bool  // a concurrent exchange stored the jti first
+		wantStatus     int
+		wantCodeLookup bool
+	}{
+		{"current code", code, false, 1, 0, false, 200, true},
+		{"challenge used before", code, true, 1, 0, false, 401, false},
+		{"time step replayed", code, false, 0, 0, false, 401, true},
+		{"recovery code", "ABCDE-fghij", false, 1, 1, false, 200, true},
+		{"recovery code used before", "abcde-fghij", false, 1, 0, false, 401, true},
+		{"concurrent exchange", code, false, 1, 0, true, 401, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t)
+			if tt.challengeUsed {
+				fake.On(`FROM "revoked_tokens"`).Rows([]string{"count"}, []driver.Value{int64(1)})
+			} else {
+				fake.On(`FROM "revoked_tokens"`).Rows([]string{"count"}, []driver.Value{int64(0)})
+			}
+			fake.On(`FROM "totp_factors"`).Rows(
+				[]string{"id", "user_id", "secret", "confirmed_at", "last_used_step"},
+				[]driver.Value{int64(5), int64(42), secret, time.Now(), step - 1})
+			fake.On(`UPDATE "totp_factors" SET "last_used_step"`).Affected(tt.stepConsumed)
+			fake.On(`UPDATE "recovery_codes" SET "used_at"`).Affected(tt.recoveryUsed)
+			fake.On(`FROM "users"`).Rows(
+				[]string{"id", "tenant_id", "email", "firebase_uid"},
+				[]driver.Value{int64(42), int64(1), "ada@example.com", "uid-42"})
+			if tt.exchangeFails {
+				fake.On(`INSERT INTO "revoked_tokens"`).Fail(errors.New("duplicate key value violates unique constraint"))
+			}
+
+			status, body := postJSON(t, VerifyMFA, fmt.Sprintf(`{"mfa_token":%q,"code":%q}`, challenge, tt.code))
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+			if looked := fake.Ran(`FROM "totp_factors"`); looked != tt.wantCodeLookup {
+				t.Errorf("second factor checked = %v, want %v", looked, tt.wantCodeLookup)
+			}
+			if issued := fake.Ran(`INSERT INTO "sessions"`); issued != (tt.wantStatus == 200) {
+				t.Errorf("session started = %v, want %v", issued, tt.wantStatus == 200)
+			}
+			if tt.wantStatus == 200 && !fake.Ran(`INSERT INTO "revoked_tokens"`) {
+				t.Error("the challenge was not used up")
+			}
+		})
+	}
+
+	// recovery codes match however they are typed, by one hash
+	fake := useFakeDB(t)
+	fake.On(`FROM "revoked_tokens"`).Rows([]string{"count"}, []driver.Value{int64(0)})
+	fake.On(`FROM "totp_factors"`).Rows(
+		[]string{"id", "user_id", "secret", "confirmed_at"},
+		[]driver.Value{int64(5), int64(42), secret, time.Now()})
+	postJSON(t, VerifyMFA, fmt.Sprintf(`{"mfa_token":%q,"code":%q}`, challenge, " ABCDE fghij "))
+	args := fake.Args(`UPDATE "recovery_codes"`)
+	want := utils.HashToken("abcdefghij")
+	found := false
+	for _, arg := range args {
+		if arg == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("recovery code looked up with %v, want the hash of the normalized code", args)
+	}
+}
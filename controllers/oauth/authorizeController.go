@@ -0,0 +1,410 @@
+package oauth
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// authorizationCodeTTL is kept short, the client exchanges the code right away
+const authorizationCodeTTL = 5 * time.Minute
+
+// maxNonceLength bounds what we store and sign on behalf of the client
+const maxNonceLength = 512
+
+// authorizeRequest carries the RFC 6749 authorization request parameters.
+// The consent page reads them from the client's redirect and passes them on
+// to GET (to display) and POST (to decide) /oauth/authorize.
+type authorizeRequest struct {
+	ResponseType        string `query:"response_type" json:"response_type" form:"response_type"`
+	ClientID            string `query:"client_id" json:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" json:"scope" form:"scope"`
+	State               string `query:"state" json:"state" form:"state"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method" form:"code_challenge_method"`
+	Nonce               string `query:"nonce" json:"nonce" form:"nonce"` // OpenID Connect replay protection
+}
+
+// authorizeError is a rejected authorization request. Once the client and
+// redirect URI are trusted the error is sent back to the client (redirect);
+// before that it must only be shown to the user.
+type authorizeError struct {
+	status   int
+	message  string
+	redirect string
+}
+
+func (e *authorizeError) respond(c *fiber.Ctx) error {
+	response := fiber.Map{
+		"success": false,
+		"message": e.message,
+	}
+	if e.redirect != "" {
+		response["data"] = fiber.Map{"redirect_to": e.redirect}
+	}
+	return c.Status(e.status).JSON(response)
+}
+
+// redirectWith appends params to the client's redirect URI
+func redirectWith(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	for key, value := range params {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// validate checks the request against the registered client and returns the
+// client, redirect URI and requested scopes
+func (r *authorizeRequest) validate() (*models.OAuthClient, string, []string, *authorizeError) {
+	var client models.OAuthClient
+	if r.ClientID == "" || database.DB.Where("client_id = ?", r.ClientID).First(&client).Error != nil {
+		return nil, "", nil, &authorizeError{status: 400, message: "Unknown client"}
+	}
+
+	redirectURI := r.RedirectURI
+	if redirectURI == "" {
+		// may only be omitted when it is unambiguous
+		if uris := client.RedirectURIList(); len(uris) == 1 {
+			redirectURI = uris[0]
+		}
+	}
+	if redirectURI == "" || !client.AllowsRedirectURI(redirectURI) {
+		return nil, "", nil, &authorizeError{status: 400, message: "Redirect URI is not registered for this client"}
+	}
+
+	// From here on errors go back to the client
+	fail := func(code, description string) *authorizeError {
+		return &authorizeError{
+			status:  400,
+			message: description,
+			redirect: redirectWith(redirectURI, map[string]string{
+				"error":             code,
+				"error_description": description,
+				"state":             r.State,
+			}),
+		}
+	}
+
+	if !client.AllowsGrantType(GrantAuthorizationCode) {
+		return nil, "", nil, fail("unauthorized_client", "Client is not allowed to use the authorization code flow")
+	}
+	if r.ResponseType != "code" {
+		return nil, "", nil, fail("unsupported_response_type", "Only response_type=code is supported")
+	}
+
+	// PKCE is mandatory for public clients and S256 is the only method
+	if r.CodeChallenge != "" || r.CodeChallengeMethod != "" {
+		if r.CodeChallengeMethod != "S256" || len(r.CodeChallenge) != 43 {
+			return nil, "", nil, fail("invalid_request", "code_challenge must be a S256 challenge")
+		}
+	} else if client.Public {
+		return nil, "", nil, fail("invalid_request", "PKCE is required for public clients")
+	}
+
+	if len(r.Nonce) > maxNonceLength {
+		return nil, "", nil, fail("invalid_request", "nonce is too long")
+	}
+
+	scopes := parseScope(r.Scope)
+	if len(scopes) == 0 {
+		scopes = client.ScopeList()
+	}
+	for _, scope := range scopes {
+		if !client.AllowsScope(scope) {
+			return nil, "", nil, fail("invalid_scope", "Scope "+scope+" is not allowed for this client")
+		}
+	}
+
+	return &client, redirectURI, scopes, nil
+}
+
+// parseScope splits a space separated scope parameter, dropping duplicates
+func parseScope(raw string) []string {
+	scopes := []string{}
+	for _, scope := range strings.Fields(raw) {
+		if !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// hasConsent reports whether the user already granted the client all scopes
+func hasConsent(userID, clientID uint, scopes []string) bool {
+	var consent models.OAuthConsent
+	if err := database.DB.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error; err != nil {
+		return false
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range scopes {
+		if !contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Authorize validates an authorization request for the consent page and
+// tells it what the client asks for. When the user already consented to
+// these scopes the page can approve without asking again.
+func Authorize(c *fiber.Ctx) error {
+	var req authorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	client, redirectURI, scopes, authErr := req.validate()
+	if authErr != nil {
+		return authErr.respond(c)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Authorization request is valid",
+		"data": fiber.Map{
+			"client": fiber.Map{
+				"client_id": client.ClientID,
+				"name":      client.Name,
+			},
+			"scopes":           scopes,
+			"redirect_uri":     redirectURI,
+			"state":            req.State,
+			"consent_required": !hasConsent(userID, client.ID, scopes),
+		},
+	})
+}
+
+// AuthorizeDecision records the user's answer on the consent page and
+// returns where to send the browser: back to the client with an
+// authorization code, or with error=access_denied.
+func AuthorizeDecision(c *fiber.Ctx) error {
+	type Req struct {
+		authorizeRequest
+		Approve bool `json:"approve" form:"approve"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	client, redirectURI, scopes, authErr := req.validate()
+	if authErr != nil {
+		return authErr.respond(c)
+	}
+
+	if !req.Approve {
+		return c.Status(200).JSON(fiber.Map{
+			"success": true,
+			"message": "Authorization denied",
+			"data": fiber.Map{
+				"redirect_to": redirectWith(redirectURI, map[string]string{
+					"error":             "access_denied",
+					"error_description": "The user denied the request",
+					"state":             req.State,
+				}),
+			},
+		})
+	}
+
+	code, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate authorization code",
+		})
+	}
+
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		// remember the consent, widening an earlier one
+		var consent models.OAuthConsent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND client_id = ?", userID, client.ID).
+			First(&consent).Error
+		switch {
+		case err == nil:
+			granted := strings.Fields(consent.Scope)
+			for _, scope := range scopes {
+				if !contains(granted, scope) {
+					granted = append(granted, scope)
+				}
+			}
+			if err := tx.Model(&consent).Update("scope", strings.Join(granted, " ")).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(&models.OAuthConsent{
+				UserID:   userID,
+				ClientID: client.ID,
+				Scope:    strings.Join(scopes, " "),
+			}).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&models.OAuthAuthorizationCode{
+			CodeHash:            utils.HashToken(code),
+			ClientID:            client.ID,
+			UserID:              userID,
+			RedirectURI:         redirectURI,
+			Scope:               strings.Join(scopes, " "),
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+			Nonce:               req.Nonce,
+			ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save authorization",
+		})
+	}
+	audit.Success(c, audit.ActionConsentGrant, userID, map[string]interface{}{
+		"client_id": client.ClientID,
+		"scopes":    scopes,
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Authorization granted",
+		"data": fiber.Map{
+			"redirect_to": redirectWith(redirectURI, map[string]string{
+				"code":  code,
+				"state": req.State,
+			}),
+		},
+	})
+}
+
+// ListConsents returns the applications the current user has authorized
+func ListConsents(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var consents []models.OAuthConsent
+	if err := database.DB.WithContext(c.UserContext()).Preload("Client").Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(consents))
+	for _, consent := range consents {
+		data = append(data, fiber.Map{
+			"client_id":  consent.Client.ClientID,
+			"name":       consent.Client.Name,
+			"scopes":     strings.Fields(consent.Scope),
+			"granted_at": consent.CreatedAt,
+			"updated_at": consent.UpdatedAt,
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Authorized applications retrieved",
+		"data":    data,
+	})
+}
+
+// RevokeConsent withdraws the current user's authorization of a client and
+// revokes its refresh tokens, the access tokens it still holds and the
+// codes it has not redeemed yet.
+func RevokeConsent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var client models.OAuthClient
+	if err := database.DB.WithContext(c.UserContext()).Where("client_id = ?", c.Params("clientId")).First(&client).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Client not found",
+		})
+	}
+
+	err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("client_id = ? AND user_id = ? AND revoked_at IS NULL", client.ID, userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := revokeAccessTokens(tx.Where("client_id = ? AND user_id = ?", client.ID, userID)); err != nil {
+			return err
+		}
+
+		// codes not redeemed yet would start new token families
+		if err := tx.Model(&models.OAuthAuthorizationCode{}).
+			Where("client_id = ? AND user_id = ? AND used_at IS NULL", client.ID, userID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("client_id = ? AND user_id = ?", client.ID, userID).Delete(&models.OAuthConsent{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to revoke authorization",
+		})
+	}
+	audit.Success(c, audit.ActionConsentRevoke, userID, map[string]interface{}{"client_id": client.ClientID})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Authorization revoked",
+	})
+}
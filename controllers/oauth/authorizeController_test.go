@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRevokeConsent(t *testing.T) {
+	fake := useFakeDB(t)
+	fake.On(`FROM "o_auth_clients"`).Rows(
+		[]string{"id", "client_id"},
+		[]driver.Value{int64(4), "oc_app"})
+	fake.On(`FROM "o_auth_access_tokens"`).Rows(
+		[]string{"id", "jti", "client_id", "user_id", "expires_at"},
+		[]driver.Value{int64(1), "live-jti", int64(4), int64(42), time.Now().Add(time.Hour)})
+
+	app := fiber.New()
+	app.Delete("/consents/:clientId", func(c *fiber.Ctx) error {
+		c.Locals("user_id", uint(42))
+		return c.Next()
+	}, RevokeConsent)
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, "/consents/oc_app", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	tests := []struct {
+		name      string
+		fragments []string
+	}{
+		{"refresh tokens revoked", []string{`UPDATE "o_auth_refresh_tokens" SET "revoked_at"`, "client_id", "user_id"}},
+		{"live access tokens denylisted", []string{`INSERT INTO "revoked_tokens"`}},
+		{"access tokens forgotten", []string{`DELETE FROM "o_auth_access_tokens"`, "client_id", "user_id"}},
+		{"pending codes used up", []string{`UPDATE "o_auth_authorization_codes" SET "used_at"`, "used_at IS NULL"}},
+		{"consent removed", []string{`o_auth_consents`, "client_id", "user_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !fake.Ran(tt.fragments...) {
+				t.Errorf("no statement with %q", tt.fragments)
+			}
+		})
+	}
+	if !hasArg(fake.Args(`INSERT INTO "revoked_tokens"`), "live-jti") {
+		t.Error("the live access token was not denylisted")
+	}
+}
@@ -0,0 +1,273 @@
+package oauth
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Grant types supported by the token endpoint
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+var grantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+// validRedirectURI accepts absolute URIs without fragment. Plain http is
+// only allowed for loopback addresses (local development, native apps);
+// custom schemes are allowed for native apps.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(raw, "#") {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	case "javascript", "data", "file":
+		return false
+	}
+	return true
+}
+
+// clientResponse is a client as shown to its owner; secret is only set on
+// registration
+func clientResponse(client models.OAuthClient, secret string) fiber.Map {
+	data := fiber.Map{
+		"id":            client.ID,
+		"client_id":     client.ClientID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIList(),
+		"scopes":        client.ScopeList(),
+		"grant_types":   client.GrantTypeList(),
+		"public":        client.Public,
+		"created_at":    client.CreatedAt,
+	}
+	if secret != "" {
+		data["client_secret"] = secret
+	}
+	return data
+}
+
+// RegisterClient registers a third-party application owned by the current
+// user. Confidential clients receive a secret that is shown only once.
+func RegisterClient(c *fiber.Ctx) error {
+	type Req struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		GrantTypes   []string `json:"grant_types"` // default authorization_code + refresh_token
+		Public       bool     `json:"public"`      // mobile or browser app that cannot keep a secret
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Name and at least one scope are required",
+		})
+	}
+	for _, scope := range req.Scopes {
+		if !utils.ValidOAuthScope(scope) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Unknown scope " + scope,
+				"data":    fiber.Map{"allowed_scopes": utils.OAuthScopes},
+			})
+		}
+	}
+
+	if len(req.GrantTypes) == 0 {
+		req.GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+	}
+	grants := map[string]bool{}
+	for _, grant := range req.GrantTypes {
+		if !contains(grantTypes, grant) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Unsupported grant type " + grant,
+				"data":    fiber.Map{"allowed_grant_types": grantTypes},
+			})
+		}
+		grants[grant] = true
+	}
+	if req.Public && grants[GrantClientCredentials] {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Public clients cannot use the client_credentials grant",
+		})
+	}
+
+	if grants[GrantAuthorizationCode] && len(req.RedirectURIs) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "At least one redirect URI is required",
+		})
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid redirect URI " + uri,
+			})
+		}
+	}
+
+	clientID, err := utils.GenerateOAuthClientID()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate client credentials",
+		})
+	}
+
+	client := models.OAuthClient{
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		Scopes:       strings.Join(req.Scopes, " "),
+		GrantTypes:   strings.Join(req.GrantTypes, " "),
+		Public:       req.Public,
+		OwnerID:      userID,
+	}
+
+	var secret string
+	if !req.Public {
+		secret, err = utils.GenerateOpaqueToken()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to generate client credentials",
+			})
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&client).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save client",
+		})
+	}
+
+	message := "Client registered"
+	if secret != "" {
+		message = "Client registered. Store the secret now, it will not be shown again"
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data":    clientResponse(client, secret),
+	})
+}
+
+// ListClients returns the clients registered by the current user
+func ListClients(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var clients []models.OAuthClient
+	if err := database.DB.WithContext(c.UserContext()).Where("owner_id = ?", userID).Order("id DESC").Find(&clients).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(clients))
+	for _, client := range clients {
+		data = append(data, clientResponse(client, ""))
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Clients retrieved",
+		"data":    data,
+	})
+}
+
+// DeleteClient removes a client of the current user. Its access tokens stop
+// working with it (see middleware.oauthAuthenticator), refresh tokens and
+// consents are revoked.
+func DeleteClient(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var client models.OAuthClient
+	if err := database.DB.WithContext(c.UserContext()).Where("id = ? AND owner_id = ?", c.Params("id"), userID).First(&client).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Client not found",
+		})
+	}
+
+	err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("client_id = ? AND revoked_at IS NULL", client.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", client.ID).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&client).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete client",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Client deleted",
+	})
+}
+
+// contains reports whether list contains value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,514 @@
+package oauth
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The token, introspection and revocation endpoints are called by client
+// libraries, so they speak RFC 6749/7662/7009 (form input, error/
+// error_description responses) instead of our success/message envelope.
+
+// tokenRequest holds the parameters of all grants plus client_secret_post
+// credentials; clients send application/x-www-form-urlencoded
+type tokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	Scope        string `form:"scope" json:"scope"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+}
+
+// oauthError writes an RFC 6749 section 5.2 error response
+func oauthError(c *fiber.Ctx, status int, code, description string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// refreshTokenTTL reads OAUTH_REFRESH_TOKEN_TTL_DAYS (default 30)
+func refreshTokenTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("OAUTH_REFRESH_TOKEN_TTL_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+var errInvalidClient = errors.New("Client authentication failed")
+
+// authenticateClient identifies the calling client by HTTP Basic
+// (client_secret_basic) or by client_id/client_secret in the body
+// (client_secret_post). Public clients only send their client_id.
+func authenticateClient(c *fiber.Ctx, clientID, clientSecret string) (*models.OAuthClient, error) {
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, "Basic ") {
+		if clientSecret != "" {
+			return nil, errInvalidClient // only one authentication method per request
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+		if err != nil {
+			return nil, errInvalidClient
+		}
+		id, secret, ok := strings.Cut(string(raw), ":")
+		if !ok {
+			return nil, errInvalidClient
+		}
+		// credentials are form-urlencoded before Basic encoding
+		if clientID, err = url.QueryUnescape(id); err != nil {
+			return nil, errInvalidClient
+		}
+		if clientSecret, err = url.QueryUnescape(secret); err != nil {
+			return nil, errInvalidClient
+		}
+	}
+
+	if clientID == "" {
+		return nil, errInvalidClient
+	}
+
+	var client models.OAuthClient
+	if err := database.DB.WithContext(c.UserContext()).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, errInvalidClient
+	}
+
+	if client.Public {
+		if clientSecret != "" {
+			return nil, errInvalidClient
+		}
+		return &client, nil
+	}
+	if clientSecret == "" ||
+		subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(clientSecret))) != 1 {
+		return nil, errInvalidClient
+	}
+	return &client, nil
+}
+
+// invalidClient answers a failed client authentication
+func invalidClient(c *fiber.Ctx) error {
+	if strings.HasPrefix(c.Get(fiber.HeaderAuthorization), "Basic ") {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return oauthError(c, 401, "invalid_client", errInvalidClient.Error())
+}
+
+// revokeAccessTokens denylists the still valid access tokens matched by tx,
+// which are self-contained, and forgets all of them
+func revokeAccessTokens(tx *gorm.DB) error {
+	var issued []models.OAuthAccessToken
+	if err := tx.Session(&gorm.Session{}).Where("expires_at > ?", time.Now()).Find(&issued).Error; err != nil {
+		return err
+	}
+	for _, token := range issued {
+		if err := tx.Session(&gorm.Session{NewDB: true}).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+			JTI:       token.JTI,
+			UserID:    token.UserID,
+			ExpiresAt: token.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&models.OAuthAccessToken{}).Error
+}
+
+// issueTokens signs an access token, an ID token for openid and, for
+// offline_access, starts or continues a refresh token family
+func issueTokens(tx *gorm.DB, client *models.OAuthClient, userID uint, scope, familyID string, authorizationID *uint, nonce string) (fiber.Map, error) {
+	access, err := utils.GenerateOAuthAccessToken(client.ClientID, userID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// user tokens are remembered until they expire, for RevokeConsent
+	if userID != 0 {
+		tx.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAccessToken{})
+		if err := tx.Create(&models.OAuthAccessToken{
+			JTI:             access.JTI,
+			ClientID:        client.ID,
+			UserID:          userID,
+			AuthorizationID: authorizationID,
+			ExpiresAt:       access.ExpiresAt,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	response := fiber.Map{
+		"access_token": access.Token,
+		"token_type":   "Bearer",
+		"expires_in":   access.ExpiresIn,
+		"scope":        scope,
+	}
+
+	if userID != 0 && containsField(scope, utils.ScopeOpenID) {
+		var user models.User
+		if err := tx.Preload("User_Details").First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		idToken, err := utils.GenerateIDToken(client.ClientID, oidcProfile(user), scope, nonce)
+		if err != nil {
+			return nil, err
+		}
+		response["id_token"] = idToken
+	}
+
+	if userID == 0 || !containsField(scope, utils.ScopeOfflineAccess) || !client.AllowsGrantType(GrantRefreshToken) {
+		return response, nil
+	}
+
+	refresh, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = utils.GenerateOpaqueToken(); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Create(&models.OAuthRefreshToken{
+		TokenHash:       utils.HashToken(refresh),
+		FamilyID:        familyID,
+		ClientID:        client.ID,
+		UserID:          userID,
+		AuthorizationID: authorizationID,
+		Scope:           scope,
+		ExpiresAt:       time.Now().Add(refreshTokenTTL()),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	response["refresh_token"] = refresh
+	return response, nil
+}
+
+// containsField reports whether the space separated list contains value
+func containsField(list, value string) bool {
+	return contains(strings.Fields(list), value)
+}
+
+// Token is the OAuth 2.0 token endpoint (authorization_code with PKCE,
+// refresh_token, client_credentials)
+func Token(c *fiber.Ctx) error {
+	// tokens must never be cached (RFC 6749 section 5.1)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var req tokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, 400, "invalid_request", "Invalid input")
+	}
+
+	client, err := authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return invalidClient(c)
+	}
+
+	if !contains(grantTypes, req.GrantType) {
+		return oauthError(c, 400, "unsupported_grant_type", "Unsupported grant type")
+	}
+	if !client.AllowsGrantType(req.GrantType) {
+		return oauthError(c, 400, "unauthorized_client", "Client is not allowed to use this grant type")
+	}
+
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		return exchangeAuthorizationCode(c, client, &req)
+	case GrantRefreshToken:
+		return exchangeRefreshToken(c, client, &req)
+	default:
+		return clientCredentials(c, client, &req)
+	}
+}
+
+// exchangeAuthorizationCode redeems a code issued by AuthorizeDecision
+func exchangeAuthorizationCode(c *fiber.Ctx, client *models.OAuthClient, req *tokenRequest) error {
+	if req.Code == "" {
+		return oauthError(c, 400, "invalid_request", "code is required")
+	}
+
+	var code models.OAuthAuthorizationCode
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("code_hash = ? AND client_id = ?", utils.HashToken(req.Code), client.ID).
+		First(&code).Error; err != nil {
+		return oauthError(c, 400, "invalid_grant", "Invalid authorization code")
+	}
+
+	// Consume the code; a second redemption means it leaked, so everything
+	// issued for it is revoked (RFC 6749 section 4.1.2)
+	now := time.Now()
+	result := database.DB.WithContext(c.UserContext()).Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return oauthError(c, 500, "server_error", "Database error")
+	}
+	if result.RowsAffected == 0 {
+		err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.OAuthRefreshToken{}).
+				Where("authorization_id = ? AND revoked_at IS NULL", code.ID).
+				Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			return revokeAccessTokens(tx.Where("authorization_id = ?", code.ID))
+		})
+		if err != nil {
+			return oauthError(c, 500, "server_error", "Database error")
+		}
+		return oauthError(c, 400, "invalid_grant", "Authorization code has already been used")
+	}
+
+	if now.After(code.ExpiresAt) {
+		return oauthError(c, 400, "invalid_grant", "Authorization code has expired")
+	}
+	// may be omitted when the client has a single redirect URI, as at /authorize
+	redirectURI := req.RedirectURI
+	if uris := client.RedirectURIList(); redirectURI == "" && len(uris) == 1 {
+		redirectURI = uris[0]
+	}
+	if redirectURI != code.RedirectURI {
+		return oauthError(c, 400, "invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if code.CodeChallenge != "" {
+		if !utils.VerifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+			return oauthError(c, 400, "invalid_grant", "Invalid code_verifier")
+		}
+	} else if req.CodeVerifier != "" {
+		return oauthError(c, 400, "invalid_grant", "code_verifier sent without code_challenge")
+	}
+
+	var response fiber.Map
+	err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		response, err = issueTokens(tx, client, code.UserID, code.Scope, "", &code.ID, code.Nonce)
+		return err
+	})
+	if err != nil {
+		return oauthError(c, 500, "server_error", "Failed to issue token")
+	}
+
+	return c.Status(200).JSON(response)
+}
+
+// exchangeRefreshToken rotates a refresh token. As for our own sessions,
+// presenting a used token revokes the whole family.
+func exchangeRefreshToken(c *fiber.Ctx, client *models.OAuthClient, req *tokenRequest) error {
+	if req.RefreshToken == "" {
+		return oauthError(c, 400, "invalid_request", "refresh_token is required")
+	}
+
+	var token models.OAuthRefreshToken
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("token_hash = ? AND client_id = ?", utils.HashToken(req.RefreshToken), client.ID).
+		First(&token).Error; err != nil {
+		return oauthError(c, 400, "invalid_grant", "Invalid refresh token")
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return oauthError(c, 400, "invalid_grant", "Refresh token expired or revoked")
+	}
+
+	// a narrower scope may be requested, never a wider one
+	scope := token.Scope
+	if requested := parseScope(req.Scope); len(requested) > 0 {
+		for _, s := range requested {
+			if !containsField(token.Scope, s) {
+				return oauthError(c, 400, "invalid_scope", "Scope "+s+" was not granted")
+			}
+		}
+		scope = strings.Join(requested, " ")
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, token.UserID).Error; err != nil {
+		return oauthError(c, 400, "invalid_grant", "User not found")
+	}
+
+	var response fiber.Map
+	err := database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthRefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		response, err = issueTokens(tx, client, token.UserID, scope, token.FamilyID, token.AuthorizationID, "")
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		database.DB.WithContext(c.UserContext()).Model(&models.OAuthRefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+			Update("revoked_at", now)
+		return oauthError(c, 400, "invalid_grant", "Refresh token reuse detected, authorization revoked")
+	}
+	if err != nil {
+		return oauthError(c, 500, "server_error", "Failed to issue token")
+	}
+
+	return c.Status(200).JSON(response)
+}
+
+// clientCredentials issues a token for the client itself, acting as the
+// user who registered it. No refresh or ID token is issued.
+func clientCredentials(c *fiber.Ctx, client *models.OAuthClient, req *tokenRequest) error {
+	// only API scopes make sense without a signed-in user
+	userScope := func(s string) bool {
+		return s == utils.ScopeOfflineAccess || contains(utils.OIDCScopes, s)
+	}
+
+	scopes := parseScope(req.Scope)
+	if len(scopes) == 0 {
+		for _, s := range client.ScopeList() {
+			if !userScope(s) {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	for _, s := range scopes {
+		if !client.AllowsScope(s) || userScope(s) {
+			return oauthError(c, 400, "invalid_scope", "Scope "+s+" is not allowed for this client")
+		}
+	}
+
+	response, err := issueTokens(database.DB.WithContext(c.UserContext()), client, 0, strings.Join(scopes, " "), "", nil, "")
+	if err != nil {
+		return oauthError(c, 500, "server_error", "Failed to issue token")
+	}
+
+	return c.Status(200).JSON(response)
+}
+
+// Introspect reports whether a token is active (RFC 7662). Clients must
+// authenticate with their secret and only learn about their own tokens.
+func Introspect(c *fiber.Ctx) error {
+	type Req struct {
+		Token         string `form:"token" json:"token"`
+		TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+		ClientID      string `form:"client_id" json:"client_id"`
+		ClientSecret  string `form:"client_secret" json:"client_secret"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return oauthError(c, 400, "invalid_request", "token is required")
+	}
+
+	client, err := authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil || client.Public {
+		return invalidClient(c)
+	}
+
+	inactive := fiber.Map{"active": false}
+
+	if claims, err := utils.ValidateOAuthAccessToken(req.Token); err == nil {
+		var revoked int64
+		if claims.ClientID != client.ClientID ||
+			database.DB.WithContext(c.UserContext()).Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked).Error != nil ||
+			revoked > 0 {
+			return c.Status(200).JSON(inactive)
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"active":     true,
+			"token_type": "access_token",
+			"client_id":  claims.ClientID,
+			"scope":      claims.Scope,
+			"sub":        claims.Subject,
+			"iss":        claims.Issuer,
+			"aud":        claims.Audience,
+			"jti":        claims.ID,
+			"exp":        claims.ExpiresAt.Unix(),
+			"iat":        claims.IssuedAt.Unix(),
+		})
+	}
+
+	var token models.OAuthRefreshToken
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("token_hash = ? AND client_id = ?", utils.HashToken(req.Token), client.ID).
+		First(&token).Error; err != nil ||
+		token.UsedAt != nil || token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return c.Status(200).JSON(inactive)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"active":     true,
+		"token_type": "refresh_token",
+		"client_id":  client.ClientID,
+		"scope":      token.Scope,
+		"sub":        strconv.FormatUint(uint64(token.UserID), 10),
+		"exp":        token.ExpiresAt.Unix(),
+		"iat":        token.CreatedAt.Unix(),
+	})
+}
+
+// Revoke invalidates an access or refresh token of the calling client
+// (RFC 7009). Revoking a refresh token ends its whole family. Unknown
+// tokens are not an error.
+func Revoke(c *fiber.Ctx) error {
+	type Req struct {
+		Token         string `form:"token" json:"token"`
+		TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+		ClientID      string `form:"client_id" json:"client_id"`
+		ClientSecret  string `form:"client_secret" json:"client_secret"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return oauthError(c, 400, "invalid_request", "token is required")
+	}
+
+	client, err := authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return invalidClient(c)
+	}
+
+	if claims, err := utils.ValidateOAuthAccessToken(req.Token); err == nil {
+		if claims.ClientID != client.ClientID {
+			return c.SendStatus(200)
+		}
+		// opportunistic cleanup keeps the denylist small
+		database.DB.WithContext(c.UserContext()).Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+		if err := database.DB.WithContext(c.UserContext()).Where("jti = ?", claims.ID).FirstOrCreate(&models.RevokedToken{
+			JTI:       claims.ID,
+			UserID:    claims.UserID,
+			ExpiresAt: claims.ExpiresAt.Time,
+		}).Error; err != nil {
+			return oauthError(c, 503, "temporarily_unavailable", "Failed to revoke token")
+		}
+		return c.SendStatus(200)
+	}
+
+	var token models.OAuthRefreshToken
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("token_hash = ? AND client_id = ?", utils.HashToken(req.Token), client.ID).
+		First(&token).Error; err != nil {
+		return c.SendStatus(200)
+	}
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.OAuthRefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return oauthError(c, 503, "temporarily_unavailable", "Failed to revoke token")
+	}
+
+	return c.SendStatus(200)
+}
@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"Auth/database"
+	"Auth/test"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// useFakeDB points database.DB at a scripted database for the test
+func useFakeDB(t *testing.T) *test.FakeDB {
+	t.Helper()
+	db, fake, err := test.OpenFakeDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return fake
+}
+
+// oauthResponse is the body of token endpoint answers
+type oauthResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// postForm sends form to the token endpoint
+func postForm(t *testing.T, form url.Values) (int, oauthResponse) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/token", Token)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body oauthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestAuthorizationCodeExchange(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-hs256")
+
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	redirectURI := "https://app.example.com/callback"
+	const codeID = int64(9)
+
+	tests := []struct {
+		name         string
+		verifier     string
+		consumed     int64 // rows the conditional update marks used
+		expiresAt    time.Time
+		wantStatus   int
+		wantError    string
+		wantIssued   bool
+		wantRevoking bool
+	}{
+		{"verifier matches", verifier, 1, time.Now().Add(time.Minute), 200, "", true, false},
+		{"wrong verifier", strings.Replace(verifier, "d", "e", 1), 1, time.Now().Add(time.Minute), 400, "invalid_grant", false, false},
+		{"verifier missing", "", 1, time.Now().Add(time.Minute), 400, "invalid_grant", false, false},
+		{"code expired", verifier, 1, time.Now().Add(-time.Minute), 400, "invalid_grant", false, false},
+		{"code reused", verifier, 0, time.Now().Add(time.Minute), 400, "invalid_grant", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t)
+			fake.On(`FROM "o_auth_clients"`).Rows(
+				[]string{"id", "client_id", "redirect_uris", "scopes", "grant_types", "public"},
+				[]driver.Value{int64(4), "oc_app", redirectURI, "profile", "authorization_code", true})
+			fake.On(`FROM "o_auth_authorization_codes"`).Rows(
+				[]string{"id", "client_id", "user_id", "redirect_uri", "scope", "code_challenge", "code_challenge_method", "expires_at"},
+				[]driver.Value{codeID, int64(4), int64(42), redirectURI, "profile", challenge, "S256", tt.expiresAt})
+			fake.On(`UPDATE "o_auth_authorization_codes" SET "used_at"`).Affected(tt.consumed)
+			fake.On(`FROM "o_auth_access_tokens"`, "authorization_id").Rows(
+				[]string{"id", "jti", "client_id", "user_id", "authorization_id", "expires_at"},
+				[]driver.Value{int64(1), "leaked-jti", int64(4), int64(42), codeID, time.Now().Add(time.Hour)})
+
+			form := url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"the-code"},
+				"redirect_uri": {redirectURI},
+				"client_id":    {"oc_app"},
+			}
+			if tt.verifier != "" {
+				form.Set("code_verifier", tt.verifier)
+			}
+			status, body := postForm(t, form)
+			if status != tt.wantStatus || body.Error != tt.wantError {
+				t.Fatalf("answer = %d %q (%s), want %d %q", status, body.Error, body.Description, tt.wantStatus, tt.wantError)
+			}
+
+			if issued := body.AccessToken != "" && fake.Ran(`INSERT INTO "o_auth_access_tokens"`); issued != tt.wantIssued {
+				t.Errorf("access token issued = %v, want %v", issued, tt.wantIssued)
+			}
+			if tt.wantIssued && !hasArg(fake.Args(`INSERT INTO "o_auth_access_tokens"`), codeID) {
+				t.Error("the access token is not linked to the code it was issued for")
+			}
+
+			// everything issued for a reused code is revoked
+			revoked := fake.Ran(`UPDATE "o_auth_refresh_tokens" SET "revoked_at"`, "authorization_id") &&
+				hasArg(fake.Args(`INSERT INTO "revoked_tokens"`), "leaked-jti") &&
+				fake.Ran(`DELETE FROM "o_auth_access_tokens"`, "authorization_id")
+			if revoked != tt.wantRevoking {
+				t.Errorf("tokens of the code revoked = %v, want %v", revoked, tt.wantRevoking)
+			}
+		})
+	}
+}
+
+// hasArg reports whether value is among the arguments of a statement
+func hasArg(args []driver.Value, value driver.Value) bool {
+	for _, arg := range args {
+		if arg == value {
+			return true
+		}
+	}
+	return false
+}
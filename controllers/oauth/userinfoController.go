@@ -0,0 +1,66 @@
+package oauth
+
+import (
+	"Auth/database"
+	"Auth/middleware"
+	"Auth/models"
+	"Auth/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcProfile maps a user (with User_Details loaded) to OIDC claims
+func oidcProfile(user models.User) utils.OIDCProfile {
+	updatedAt := user.UpdatedAt
+	if user.User_Details.UpdatedAt.After(updatedAt) {
+		updatedAt = user.User_Details.UpdatedAt
+	}
+	return utils.OIDCProfile{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Name:          user.User_Details.Name,
+		FamilyName:    user.User_Details.Lastname,
+		Username:      user.Username,
+		UpdatedAt:     updatedAt,
+	}
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint. OAuth clients get the
+// claims of the scopes the user granted them; our own access tokens (first
+// party apps) see every claim.
+func UserInfo(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	scope := strings.Join(utils.OIDCScopes, " ")
+	if mechanism, _ := c.Locals("auth_mechanism").(middleware.AuthMechanism); mechanism == middleware.AuthOAuth {
+		claims, _ := c.Locals("oauth_claims").(*utils.OAuthClaims)
+		if claims == nil || claims.UserID == 0 {
+			// client credentials tokens do not belong to a signed-in user
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"message": "Token was not issued for a user",
+			})
+		}
+		scope = claims.Scope
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Preload("User_Details").First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	// plain claims object as required by OpenID Connect Core 5.3.2
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(200).JSON(oidcProfile(user).Claims(scope))
+}
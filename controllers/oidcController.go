@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"Auth/utils"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcBaseURL is where this service is reachable: the issuer, which OpenID
+// Connect requires to be its URL. The request's Host is never used, the
+// client controls it and the document is cached.
+func oidcBaseURL(issuer string) (string, error) {
+	if u, err := url.Parse(issuer); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		return strings.TrimRight(issuer, "/"), nil
+	}
+	return "", errors.New("the JWT issuer must be the absolute http(s) URL of this service")
+}
+
+// OpenIDConfiguration publishes the OpenID Connect discovery document
+func OpenIDConfiguration(c *fiber.Ctx) error {
+	issuer, err := utils.Issuer()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Issuer unavailable",
+		})
+	}
+	alg, err := utils.SigningAlgorithm()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Signing keys unavailable",
+		})
+	}
+
+	base, err := oidcBaseURL(issuer)
+	if err != nil {
+		log.Printf("⚠️  OpenID Connect discovery unavailable: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Issuer unavailable",
+		})
+	}
+
+	// the user approves on the frontend's consent page, which talks to
+	// /api/oauth/authorize
+	authorizationEndpoint := base + "/api/oauth/authorize"
+	if appURL, err := utils.AppURL(); err == nil {
+		authorizationEndpoint = appURL + "/oauth/authorize"
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(200).JSON(fiber.Map{
+		"issuer":                                issuer,
+		"authorization_endpoint":                authorizationEndpoint,
+		"token_endpoint":                        base + "/api/oauth/token",
+		"userinfo_endpoint":                     base + "/api/oauth/userinfo",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"revocation_endpoint":                   base + "/api/oauth/revoke",
+		"introspection_endpoint":                base + "/api/oauth/introspect",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{alg},
+		"scopes_supported":                      utils.OAuthScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "nonce", "azp",
+			"email", "email_verified", "name", "given_name", "family_name", "preferred_username", "updated_at",
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import "testing"
+
+func TestOIDCBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		issuer  string
+		want    string
+		wantErr bool
+	}{
+		{"https", "https://auth.example.com", "https://auth.example.com", false},
+		{"trailing slash", "https://auth.example.com/", "https://auth.example.com", false},
+		{"http for development", "http://localhost:3000", "http://localhost:3000", false},
+		{"plain name", "Auth", "", true},
+		{"empty", "", "", true},
+		{"no host", "https://", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := oidcBaseURL(tt.issuer)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("oidcBaseURL(%q) = %q, %v, want %q, error %v", tt.issuer, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
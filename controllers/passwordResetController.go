@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/lockout"
+	"Auth/mailer"
+	"Auth/models"
+	"Auth/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// passwordResetTTL reads PASSWORD_RESET_TTL_MINUTES (default 30)
+func passwordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// sendPasswordReset creates a reset token for the user, superseding older
+// ones, and mails the link to the frontend's reset page at appURL
+func sendPasswordReset(user models.User, appURL string) error {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ttl := passwordResetTTL()
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return mailer.Get().Send(context.Background(), mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"Open the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %d minutes and can be used once. "+
+			"If you did not ask for this, ignore this email; your password stays the same.\n",
+			user.Username, appURL+"/reset-password?token="+url.QueryEscape(token), int(ttl.Minutes())),
+	})
+}
+
+// ForgotPasswordByEmail starts a password reset. The response is the same
+// whether or not the email is registered, so it cannot be used to discover
+// accounts; the email itself is sent in the background for the same reason.
+func ForgotPasswordByEmail(c *fiber.Ctx) error {
+	type Request struct {
+		Email string `json:"email"`
+	}
+
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Email is required",
+		})
+	}
+
+	// the link opens the frontend's reset page, which POSTs the new password;
+	// it is never built from the request, whose Host the caller controls
+	appURL, err := utils.AppURL()
+	if err != nil {
+		log.Printf("⚠️  Password reset unavailable: %v", err)
+		return c.Status(503).JSON(fiber.Map{
+			"success": false,
+			"message": "Password reset is not available",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Where("LOWER(email) = LOWER(?)", req.Email).First(&user).Error; err == nil {
+		go func() {
+			if err := sendPasswordReset(user, appURL); err != nil {
+				log.Printf("⚠️  Password reset email to user %d failed: %v", user.ID, err)
+			}
+		}()
+		audit.Requested(c, audit.ActionPasswordResetRequest, user.ID, map[string]interface{}{"email": req.Email})
+	} else {
+		audit.Failure(c, audit.ActionPasswordResetRequest, 0, "unknown_email", map[string]interface{}{"email": req.Email})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password with the token from the reset email and
+// signs the user out everywhere
+func ResetPassword(c *fiber.Ctx) error {
+	type Request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.Token == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Token is required",
+		})
+	}
+	if len(req.Password) < 6 || len(req.Password) > 100 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Password must be between 6 and 100 characters",
+		})
+	}
+
+	// 1. Look the token up
+	now := time.Now()
+	var reset models.PasswordReset
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(req.Token), now).
+		First(&reset).Error; err != nil {
+		audit.Failure(c, audit.ActionPasswordReset, 0, "invalid_token", nil)
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired reset link",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, reset.UserID).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired reset link",
+		})
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to hash password",
+		})
+	}
+
+	// 2. Consume the token and set the password in one transaction. The
+	// conditional update makes the token single use and locks it, so a
+	// concurrent reset waits and then fails; when the identity provider or
+	// the database fails the token is left usable.
+	idp := identity.Get()
+	ctx := c.UserContext()
+	errTokenUsed := errors.New("reset token already used")
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", reset.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errTokenUsed
+		}
+
+		// following the emailed link also proves the address
+		updates := map[string]interface{}{"password": passwordHash}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = now
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// last, so a failure here rolls the token back as well
+		if _, err := idp.UpdateUser(ctx, user.FirebaseUID, identity.UserToUpdate{Password: &req.Password}); err != nil {
+			log.Printf("⚠️  Identity provider password update failed for user %d: %v", user.ID, err)
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, errTokenUsed) {
+		audit.Failure(c, audit.ActionPasswordReset, reset.UserID, "used_or_expired_token", nil)
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired reset link",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update password. The link can be used again",
+		})
+	}
+
+	audit.Success(c, audit.ActionPasswordReset, user.ID, nil)
+
+	// 3. Sign out everywhere: our sessions and the provider's refresh tokens
+	if err := revokeUserSessions(user.ID, "password_reset"); err != nil {
+		log.Printf("⚠️  Session revocation after password reset failed for user %d: %v", user.ID, err)
+	}
+	if err := idp.RevokeRefreshTokens(ctx, user.FirebaseUID); err != nil {
+		log.Printf("⚠️  Identity provider token revocation failed for user %d: %v", user.ID, err)
+	}
+	// the owner proved control of the mailbox, lift a lockout caused by guessing
+	if err := lockout.Clear(user.ID); err != nil {
+		log.Printf("⚠️  Login throttle reset failed for user %d: %v", user.ID, err)
+	}
+
+	if err := mailer.Get().Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Text: fmt.Sprintf("Hi %s,\n\nThe password of your account was just reset and all devices were signed out. "+
+			"If this was not you, contact support immediately.\n", user.Username),
+	}); err != nil {
+		log.Printf("⚠️  Password change notification to user %d failed: %v", user.ID, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Password has been reset. Please sign in again",
+	})
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/firebase"
+	"Auth/models"
+	"Auth/roles"
+	"Auth/tenancy"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userRolesResponse is the payload of the grant/revoke endpoints
+func userRolesResponse(user *models.User, claimsSynced bool) fiber.Map {
+	return fiber.Map{
+		"user_id":       user.ID,
+		"roles":         firebase.GetRoleNames(user.Roles),
+		"claims_synced": claimsSynced,
+	}
+}
+
+// syncRoleClaims pushes changed roles to the identity provider. The
+// database stays the source of truth (middleware reads roles per request),
+// so a failed sync is reported but does not undo the change.
+func syncRoleClaims(c *fiber.Ctx, user *models.User) bool {
+	if err := roles.SyncClaims(c.UserContext(), user); err != nil {
+		log.Printf("⚠️  Custom claims sync failed for user %d: %v", user.ID, err)
+		return false
+	}
+	return true
+}
+
+// auditRole records a change to a role itself
+func auditRole(c *fiber.Ctx, action string, role *models.Role, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["role"] = role.Name
+	audit.Record(c, audit.Event{
+		Action:     action,
+		Outcome:    models.AuditSuccess,
+		TargetType: audit.TargetRole,
+		TargetID:   strconv.FormatUint(uint64(role.ID), 10),
+		Metadata:   metadata,
+	})
+}
+
+// roleError maps roles package errors to responses
+func roleError(c *fiber.Ctx, err error) error {
+	status, message := 500, "Database error"
+	switch {
+	case errors.Is(err, roles.ErrUserNotFound):
+		status, message = 404, "User not found"
+	case errors.Is(err, roles.ErrRoleNotFound):
+		status, message = 404, "Role not found"
+	case errors.Is(err, roles.ErrRoleExists):
+		status, message = 409, "Role already exists"
+	case errors.Is(err, roles.ErrLastAdmin):
+		status, message = 409, "Cannot revoke the admin role from the last admin"
+	case errors.Is(err, roles.ErrInvalidRoleName):
+		status, message = 400, "Role names are 2-50 lowercase letters, digits, '_' or '-'"
+	case errors.Is(err, roles.ErrUnknownPermission):
+		status, message = 400, "Unknown permission, see /admin/permissions"
+	case errors.Is(err, roles.ErrAdminLockout):
+		status, message = 409, "The admin role must keep the "+models.PermUserAdmin+" permission"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
+// ListRoles returns every role with the number of users holding it
+func ListRoles(c *fiber.Ctx) error {
+	type roleRow struct {
+		ID    uint   `json:"id"`
+		Name  string `json:"name"`
+		Users int64  `json:"users"`
+	}
+
+	// roles are shared by all tenants, the counts are not. The join is not
+	// rewritten by the tenancy callbacks, so it carries the tenant itself
+	// and, like them, fails closed without one.
+	tenantID, ok := tenancy.FromContext(c.UserContext())
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	var rows []roleRow
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.Role{}).
+		Select("roles.id, roles.name, COUNT(users.id) AS users").
+		Joins("LEFT JOIN user_roles ON user_roles.role_id = roles.id").
+		Joins("LEFT JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL AND users.tenant_id = ?", tenantID).
+		Group("roles.id, roles.name").
+		Order("roles.id").
+		Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Roles retrieved",
+		"data":    rows,
+	})
+}
+
+// CreateRole adds a custom role
+func CreateRole(c *fiber.Ctx) error {
+	type Req struct {
+		Name string `json:"name"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	role, err := roles.Create(strings.TrimSpace(req.Name))
+	if err != nil {
+		return roleError(c, err)
+	}
+	auditRole(c, audit.ActionRoleCreate, role, nil)
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "Role created",
+		"data": fiber.Map{
+			"id":   role.ID,
+			"name": role.Name,
+		},
+	})
+}
+
+// ListUserRoles returns the roles of a user
+func ListUserRoles(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("userId")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, uint(userID)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "User roles retrieved",
+		"data": fiber.Map{
+			"user_id": user.ID,
+			"roles":   firebase.GetRoleNames(user.Roles),
+		},
+	})
+}
+
+// GrantRole gives a user a role and re-syncs their custom claims
+func GrantRole(c *fiber.Ctx) error {
+	type Req struct {
+		Role string `json:"role"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.Role == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Role is required",
+		})
+	}
+
+	userID, err := c.ParamsInt("userId")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	user, changed, err := roles.Grant(c.UserContext(), uint(userID), req.Role)
+	if err != nil {
+		return roleError(c, err)
+	}
+
+	message := "User already has role " + req.Role
+	synced := true
+	if changed {
+		message = "Role granted"
+		synced = syncRoleClaims(c, user)
+		audit.Success(c, audit.ActionRoleGrant, user.ID, map[string]interface{}{"role": req.Role})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data":    userRolesResponse(user, synced),
+	})
+}
+
+// RevokeRole takes a role from a user and re-syncs their custom claims
+func RevokeRole(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("userId")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	roleName := c.Params("role")
+	user, changed, err := roles.Revoke(c.UserContext(), uint(userID), roleName)
+	if err != nil {
+		return roleError(c, err)
+	}
+
+	message := "User does not have role " + roleName
+	synced := true
+	if changed {
+		message = "Role revoked"
+		synced = syncRoleClaims(c, user)
+		audit.Success(c, audit.ActionRoleRevoke, user.ID, map[string]interface{}{"role": roleName})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data":    userRolesResponse(user, synced),
+	})
+}
+
+// ListPermissions returns every permission with the roles granting it
+func ListPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").Order("name").Find(&permissions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(permissions))
+	for _, permission := range permissions {
+		data = append(data, fiber.Map{
+			"name":        permission.Name,
+			"description": permission.Description,
+			"roles":       firebase.GetRoleNames(permission.Roles),
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Permissions retrieved",
+		"data":    data,
+	})
+}
+
+// SetRolePermissions replaces the permissions of a role
+func SetRolePermissions(c *fiber.Ctx) error {
+	type Req struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.Permissions == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Permissions are required",
+		})
+	}
+
+	role, err := roles.SetPermissions(c.Params("role"), req.Permissions)
+	if err != nil {
+		return roleError(c, err)
+	}
+	auditRole(c, audit.ActionRolePermissions, role, map[string]interface{}{"permissions": req.Permissions})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Role permissions updated",
+		"data": fiber.Map{
+			"role":        role.Name,
+			"permissions": req.Permissions,
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestListUserRolesRejectsInvalidIDs(t *testing.T) {
+	app := fiber.New()
+	app.Get("/users/:userId/roles", ListUserRoles)
+
+	// each is refused with 400 before the database is asked
+	tests := []struct {
+		name   string
+		userID string
+	}{
+		{"not a number", "abc"},
+		{"sql", "1%20OR%201=1"},
+		{"zero", "0"},
+		{"negative", "-1"},
+		{"fraction", "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/users/"+tt.userID+"/roles", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 400 {
+				t.Errorf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
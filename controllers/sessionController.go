@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// currentSessionID is the session of the presented access token, 0 when the
+// request was authenticated by another mechanism (e.g. a Firebase ID token)
+func currentSessionID(c *fiber.Ctx) uint {
+	if claims, ok := c.Locals("jwt_claims").(*utils.JWTClaims); ok {
+		return claims.SessionID
+	}
+	return 0
+}
+
+// ListSessions returns the signed-in devices of the current user, most
+// recently active first
+func ListSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var sessions []models.Session
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	current := currentSessionID(c)
+	data := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, fiber.Map{
+			"id":           session.ID,
+			"provider":     session.Provider,
+			"device":       session.Device,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == current,
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Sessions retrieved",
+		"data":    data,
+	})
+}
+
+// RevokeSession signs out one device of the current user
+func RevokeSession(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var session models.Session
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Params("id"), userID).
+		First(&session).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Session not found",
+		})
+	}
+
+	if err := revokeSession(session.ID, "revoked_by_user"); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to revoke session",
+		})
+	}
+	audit.Success(c, audit.ActionSessionRevoke, userID, map[string]interface{}{"session_id": session.ID})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions signs out every device except the one making the
+// request. Without a session of our own (Firebase ID token) all are revoked.
+func RevokeOtherSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	result := database.DB.WithContext(c.UserContext()).Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID(c)).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": "revoked_by_user",
+		})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to revoke sessions",
+		})
+	}
+	audit.Success(c, audit.ActionSessionRevoke, userID, map[string]interface{}{
+		"except_session_id": currentSessionID(c),
+		"revoked":           result.RowsAffected,
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Other sessions revoked",
+		"data": fiber.Map{
+			"revoked": result.RowsAffected,
+		},
+	})
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"Auth/accounts"
+	"Auth/audit"
+	"Auth/database"
+	"Auth/identity"
+	"Auth/middleware"
+	"Auth/models"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sessionCookieMaxAuthAge is how recently the user must have signed in for
+// their ID token to be exchanged for a session cookie
+const sessionCookieMaxAuthAge = 5 * time.Minute
+
+// sessionCookieTTL reads SESSION_COOKIE_TTL_HOURS (default 120, at most 336,
+// the identity provider's limit of two weeks)
+func sessionCookieTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("SESSION_COOKIE_TTL_HOURS"))
+	if err != nil || hours <= 0 || hours > 336 {
+		hours = 120
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// setSessionCookies sets the HttpOnly session cookie and the readable CSRF
+// cookie that goes with it
+func setSessionCookies(c *fiber.Ctx, cookie string, expiresAt time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    cookie,
+		Path:     "/",
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    middleware.CSRFToken(cookie),
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+// clearSessionCookies removes the session and CSRF cookies from the browser
+func clearSessionCookies(c *fiber.Ctx) {
+	for _, name := range []string{middleware.SessionCookieName, middleware.CSRFCookieName} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Now().Add(-time.Hour),
+			HTTPOnly: name == middleware.SessionCookieName,
+			Secure:   true,
+			SameSite: "Lax",
+		})
+	}
+}
+
+// revokeSessionCookie denylists one session cookie until it expires. The
+// identity provider can only revoke all sessions of a user at once.
+func revokeSessionCookie(cookie string, userID uint, token *identity.Token) error {
+	// opportunistic cleanup keeps the denylist small
+	database.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return database.DB.Create(&models.RevokedToken{
+		JTI:       middleware.SessionCookieID(cookie),
+		UserID:    userID,
+		ExpiresAt: time.Unix(token.Expires, 0),
+	}).Error
+}
+
+// CreateSession exchanges the ID token of a fresh sign-in for an HttpOnly
+// session cookie, so browser clients need not keep a bearer token in
+// JavaScript. Requests authenticated by the cookie that change state must
+// send the returned csrf_token (also in the csrf_token cookie) in the
+// X-CSRF-Token header. Users with a second factor send their current TOTP
+// or a recovery code as mfa_code; without it the answer is a 401 with
+// mfa_required, as the cookie would otherwise skip the second factor.
+func CreateSession(c *fiber.Ctx) error {
+	type Req struct {
+		IdToken string `json:"id_token"`
+		MFACode string `json:"mfa_code"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.IdToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Firebase token is required",
+		})
+	}
+
+	idp := identity.Get()
+	token, err := idp.VerifyIDToken(c.UserContext(), req.IdToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired Firebase token",
+		})
+	}
+
+	// an old or stolen ID token must not turn into a long-lived cookie
+	signedInAt := token.IssuedAt
+	if authTime, ok := token.Claims["auth_time"].(float64); ok {
+		signedInAt = int64(authTime)
+	}
+	if time.Since(time.Unix(signedInAt, 0)) > sessionCookieMaxAuthAge {
+		audit.Failure(c, audit.ActionSessionCreate, 0, "stale_sign_in", nil)
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Recent sign-in required",
+		})
+	}
+
+	record, err := idp.GetUser(c.UserContext(), token.UID)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get user from Firebase",
+		})
+	}
+	user, err := accounts.Resolve(c.UserContext(), record)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, accounts.ErrEmailTaken) ||
+			errors.Is(err, accounts.ErrIdentityLinked) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "User not registered",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	if required, err := middleware.MFAEnrolled(c, user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	} else if required {
+		if req.MFACode == "" {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Two-factor code required",
+				"data": fiber.Map{
+					"mfa_required": true,
+				},
+			})
+		}
+		valid, err := verifySecondFactor(user.ID, req.MFACode)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		if !valid {
+			audit.Failure(c, audit.ActionSessionCreate, user.ID, "invalid_mfa_code", nil)
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid code",
+			})
+		}
+	}
+
+	ttl := sessionCookieTTL()
+	cookie, err := idp.CreateSessionCookie(c.UserContext(), req.IdToken, ttl)
+	if err != nil {
+		if errors.Is(err, identity.ErrInvalidToken) || errors.Is(err, identity.ErrTokenRevoked) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid or expired Firebase token",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create session cookie",
+		})
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	setSessionCookies(c, cookie, expiresAt)
+	audit.Success(c, audit.ActionSessionCreate, user.ID, map[string]interface{}{
+		"provider":   accounts.SignInProvider(token, record),
+		"expires_at": expiresAt,
+	})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Session created",
+		"data": fiber.Map{
+			"user_id":    user.ID,
+			"csrf_token": middleware.CSRFToken(cookie),
+			"expires_at": expiresAt,
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/tenancy"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CurrentTenant returns the job board the request was resolved to
+func CurrentTenant(c *fiber.Ctx) error {
+	var tenant models.Tenant
+	if err := database.DB.WithContext(tenancy.System(context.Background())).
+		First(&tenant, c.Locals("tenant_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Unknown tenant",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Tenant retrieved",
+		"data":    tenant,
+	})
+}
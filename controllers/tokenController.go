@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/firebase"
+	"Auth/lockout"
+	"Auth/models"
+	"Auth/roles"
+	"Auth/utils"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// tokenPair is what a successful login hands back to the client:
+// a short lived access JWT plus the opaque refresh token that renews it
+type tokenPair struct {
+	Access  *utils.TokenWithExpiry
+	Refresh *utils.TokenWithExpiry
+}
+
+// issueTokenPair starts a new session (refresh token family) for the user,
+// remembering the device it was started from.
+// Reaching it means the login is complete, so failed attempts are forgotten
+// and the login is audited.
+func issueTokenPair(c *fiber.Ctx, user models.User, provider string) (*tokenPair, error) {
+	if err := lockout.RecordSuccess(user.ID, c.IP()); err != nil {
+		log.Printf("⚠️  Login throttle reset failed for user %d: %v", user.ID, err)
+	}
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	session := models.Session{
+		UserID:     user.ID,
+		Provider:   provider,
+		UserAgent:  userAgent,
+		Device:     utils.DeviceName(userAgent),
+		IP:         c.IP(),
+		LastSeenAt: time.Now(),
+	}
+	if err := database.DB.WithContext(c.UserContext()).Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	pair, err := rotateSession(c, user, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Success(c, audit.ActionLogin, user.ID, map[string]interface{}{
+		"method":     provider,
+		"session_id": session.ID,
+	})
+	return pair, nil
+}
+
+// rotateSession adds a fresh refresh token to the session and signs a new access token for it.
+// user must have its roles loaded.
+func rotateSession(c *fiber.Ctx, user models.User, session *models.Session) (*tokenPair, error) {
+	refresh, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.RefreshToken{
+			SessionID: session.ID,
+			TokenHash: utils.HashToken(refresh.Token),
+			ExpiresAt: refresh.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		// sliding expiry: the family lives as long as its newest token
+		return tx.Model(session).Updates(map[string]interface{}{
+			"expires_at":   refresh.ExpiresAt,
+			"ip":           c.IP(),
+			"last_seen_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := utils.GenerateSessionJWT(user.ID, user.Email, user.FirebaseUID, roles.Primary(user.Roles), session.ID, user.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{Access: access, Refresh: refresh}, nil
+}
+
+// revokeSession revokes a whole refresh token family
+func revokeSession(sessionID uint, reason string) error {
+	return database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// revokeUserSessions revokes every refresh token family of the user
+func revokeUserSessions(userID uint, reason string) error {
+	return database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// revokeAccessToken denylists an access token by jti until it expires
+func revokeAccessToken(claims *utils.JWTClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	// opportunistic cleanup keeps the denylist small
+	database.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return database.DB.Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error
+}
+
+// loginResponse is the data payload shared by every login endpoint
+func loginResponse(user models.User, pair *tokenPair) fiber.Map {
+	return fiber.Map{
+		"token":              pair.Access,        // JWT token for API authentication
+		"refresh_token":      pair.Refresh.Token, // opaque, single use, exchange at /auth/refresh
+		"refresh_expires_at": pair.Refresh.ExpiresAt,
+		"user": fiber.Map{
+			"id":             user.ID,                           // Database user ID
+			"uid":            user.FirebaseUID,                  // Firebase unique identifier
+			"email":          user.Email,                        // User's email address
+			"provider":       user.Provider,                     // Authentication provider
+			"email_verified": user.EmailVerifiedAt != nil,       // Address confirmed via link or provider
+			"roles":          firebase.GetRoleNames(user.Roles), // Array of role names (e.g., ["user", "admin"])
+		},
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair.
+// The presented token is consumed; presenting it a second time is treated
+// as theft and revokes the whole token family.
+func RefreshToken(c *fiber.Ctx) error {
+	type Req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+	if req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Refresh token is required",
+		})
+	}
+
+	// 1. Look the token up by hash
+	var token models.RefreshToken
+	if err := database.DB.WithContext(c.UserContext()).
+		Preload("Session").
+		Where("token_hash = ?", utils.HashToken(req.RefreshToken)).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid refresh token",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	session := token.Session
+	now := time.Now()
+
+	// 2. Family must still be alive
+	if session.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Refresh token expired or revoked",
+		})
+	}
+
+	// 3. Reload the user so the new access token reflects current data. On
+	// another tenant's host the user does not exist: refuse before the token
+	// is consumed, so the owner's session survives a request to the wrong board
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Preload("Roles").First(&user, session.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "User not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	// 4. Consume the token; losing the race counts as reuse too
+	result := database.DB.WithContext(c.UserContext()).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if result.RowsAffected == 0 {
+		audit.Failure(c, audit.ActionTokenReuse, session.UserID, "refresh_token_reuse", map[string]interface{}{
+			"session_id": session.ID,
+		})
+		if err := revokeSession(session.ID, "refresh_token_reuse"); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Refresh token reuse detected, session revoked",
+		})
+	}
+
+	// 5. Rotate
+	pair, err := rotateSession(c, user, &session)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Token refreshed",
+		"data": fiber.Map{
+			"token":              pair.Access,
+			"refresh_token":      pair.Refresh.Token,
+			"refresh_expires_at": pair.Refresh.ExpiresAt,
+		},
+	})
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"Auth/database"
+	"Auth/tenancy"
+	"Auth/test"
+	"Auth/utils"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// useFakeDB points database.DB at a scripted database for the test
+func useFakeDB(t *testing.T) *test.FakeDB {
+	t.Helper()
+	db, fake, err := test.OpenFakeDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenancy.Register(db); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return fake
+}
+
+// postJSON sends body to handler on tenant 1 and decodes the answer
+func postJSON(t *testing.T, handler fiber.Handler, body string) (int, map[string]interface{}) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		c.SetUserContext(tenancy.WithTenant(c.UserContext(), 1))
+		return c.Next()
+	}, handler)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var answer map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, answer
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-hs256")
+	presented := "presented-refresh-token"
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		consumed    int64 // rows the conditional update marks used
+		userFound   bool
+		wantStatus  int
+		wantRotated bool
+		wantRevoked bool
+	}{
+		{"first use rotates", 1, true, 200, true, false},
+		{"reuse revokes the family", 0, true, 401, false, true},
+		{"user of another tenant", 1, false, 401, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t)
+			fake.On(`FROM "refresh_tokens" WHERE token_hash`).Rows(
+				[]string{"id", "session_id", "token_hash", "expires_at", "used_at"},
+				[]driver.Value{int64(7), int64(3), utils.HashToken(presented), future, nil})
+			fake.On(`FROM "sessions"`).Rows(
+				[]string{"id", "user_id", "expires_at", "revoked_at"},
+				[]driver.Value{int64(3), int64(42), future, nil})
+			if tt.userFound {
+				fake.On(`FROM "users"`).Rows(
+					[]string{"id", "tenant_id", "email", "firebase_uid"},
+					[]driver.Value{int64(42), int64(1), "ada@example.com", "uid-42"})
+			}
+			fake.On(`UPDATE "refresh_tokens" SET "used_at"`).Affected(tt.consumed)
+
+			status, body := postJSON(t, RefreshToken, `{"refresh_token":"`+presented+`"}`)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+
+			rotated := fake.Ran(`INSERT INTO "refresh_tokens"`)
+			if rotated != tt.wantRotated {
+				t.Errorf("new refresh token stored = %v, want %v", rotated, tt.wantRotated)
+			}
+			if tt.wantRotated {
+				data, _ := body["data"].(map[string]interface{})
+				if next, _ := data["refresh_token"].(string); next == "" || next == presented {
+					t.Errorf("refresh_token = %q, want a new token", next)
+				}
+			}
+			if revoked := fake.Ran(`UPDATE "sessions" SET "revoked_at"`); revoked != tt.wantRevoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			if !tt.userFound && fake.Ran(`UPDATE "refresh_tokens" SET "used_at"`) {
+				t.Error("the token was consumed for a user this tenant does not have")
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		stored     bool
+		revoked    bool
+		wantStatus int
+	}{
+		{"missing token", `{}`, false, false, 400},
+		{"unknown token", `{"refresh_token":"unknown"}`, false, false, 401},
+		{"revoked family", `{"refresh_token":"known"}`, true, true, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t)
+			if tt.stored {
+				var revokedAt interface{}
+				if tt.revoked {
+					revokedAt = time.Now().Add(-time.Minute)
+				}
+				fake.On(`FROM "refresh_tokens" WHERE token_hash`).Rows(
+					[]string{"id", "session_id", "expires_at"},
+					[]driver.Value{int64(7), int64(3), time.Now().Add(time.Hour)})
+				fake.On(`FROM "sessions"`).Rows(
+					[]string{"id", "user_id", "revoked_at"},
+					[]driver.Value{int64(3), int64(42), revokedAt})
+			}
+
+			status, body := postJSON(t, RefreshToken, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+			if fake.Ran(`UPDATE "refresh_tokens"`) {
+				t.Error("a refused token was consumed")
+			}
+		})
+	}
+}
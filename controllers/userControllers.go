@@ -25,7 +25,7 @@ func GetUserProfile(c *fiber.Ctx) error {
 	var user models.User
 
 	// Query with proper type conversion
-	result := database.DB.Preload("User_Details").Preload("Roles").
+	result := database.DB.WithContext(c.UserContext()).Preload("User_Details").Preload("Roles").
 		First(&user, uint(userID))
 
 	// Handle different error cases
@@ -62,7 +62,7 @@ func UpdateUserDetails(c *fiber.Ctx) error {
 	// Get user ID from params or from context (depends on your auth flow)
 	userIDParam := c.Params("userId")
 	var user models.User
-	if err := database.DB.First(&user, userIDParam).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).First(&user, userIDParam).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
@@ -74,11 +74,11 @@ func UpdateUserDetails(c *fiber.Ctx) error {
 
 	// Check if UserDetails already exist for this user
 	var userDetails models.User_Details
-	err := database.DB.Where("user_id = ?", user.ID).First(&userDetails).Error
+	err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", user.ID).First(&userDetails).Error
 	if err != nil {
 		// Not found → create new
 		input.UserID = user.ID
-		if err := database.DB.Create(&input).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).Create(&input).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to create user details"})
 		}
 		return c.JSON(presenters.ResponseSuccess("create success"))
@@ -90,7 +90,7 @@ func UpdateUserDetails(c *fiber.Ctx) error {
 		userDetails.Age = input.Age
 		userDetails.Dob = input.Dob
 
-		if err := database.DB.Save(&userDetails).Error; err != nil {
+		if err := database.DB.WithContext(c.UserContext()).Save(&userDetails).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"error": "Failed to update user details",
 			})
@@ -0,0 +1,550 @@
+package controllers
+
+import (
+	"Auth/audit"
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	webauthnCeremonyTTL  = 5 * time.Minute
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var (
+	webAuthn     *webauthn.WebAuthn
+	webAuthnOnce sync.Once
+)
+
+// getWebAuthn builds the relying party from WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME
+// and WEBAUTHN_RP_ORIGINS (comma separated). Returns nil when not configured.
+func getWebAuthn() *webauthn.WebAuthn {
+	webAuthnOnce.Do(func() {
+		rpID := os.Getenv("WEBAUTHN_RP_ID")
+		if rpID == "" {
+			return
+		}
+
+		name := os.Getenv("WEBAUTHN_RP_NAME")
+		if name == "" {
+			name = os.Getenv("API_NAME")
+		}
+		if name == "" {
+			name = "Auth"
+		}
+
+		var origins []string
+		for _, origin := range strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				origins = append(origins, origin)
+			}
+		}
+		if len(origins) == 0 {
+			origins = []string{"https://" + rpID}
+		}
+
+		w, err := webauthn.New(&webauthn.Config{
+			RPID:          rpID,
+			RPDisplayName: name,
+			RPOrigins:     origins,
+		})
+		if err != nil {
+			log.Printf("⚠️  WebAuthn disabled: %v", err)
+			return
+		}
+		webAuthn = w
+	})
+
+	return webAuthn
+}
+
+// webauthnUser adapts models.User to the webauthn.User interface
+type webauthnUser struct {
+	user        models.User
+	credentials []models.WebAuthnCredential
+}
+
+// webauthnUserHandle is the opaque user.id stored in the authenticator
+func webauthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return webauthnUserHandle(u.user.ID)
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	if u.user.Username != "" {
+		return u.user.Username
+	}
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, transport := range strings.Split(stored.Transports, ",") {
+			if transport != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// loadWebAuthnUser loads the user with roles and registered passkeys
+func loadWebAuthnUser(ctx context.Context, userID uint) (*webauthnUser, error) {
+	var user models.User
+	if err := database.DB.WithContext(ctx).Preload("Roles").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var credentials []models.WebAuthnCredential
+	if err := database.DB.Where("user_id = ?", userID).Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	return &webauthnUser{user: user, credentials: credentials}, nil
+}
+
+// saveWebAuthnSession stores the ceremony state and returns the opaque token
+// the client sends back with the finish call
+func saveWebAuthnSession(ceremony string, userID uint, session *webauthn.SessionData) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DB.Create(&models.WebAuthnSession{
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(webauthnCeremonyTTL),
+	}).Error
+	return token, err
+}
+
+// consumeWebAuthnSession loads and deletes a ceremony state, so a challenge
+// can only be answered once
+func consumeWebAuthnSession(ceremony, token string) (*models.WebAuthnSession, *webauthn.SessionData, error) {
+	var stored models.WebAuthnSession
+	if err := database.DB.
+		Where("token_hash = ? AND ceremony = ?", utils.HashToken(token), ceremony).
+		First(&stored).Error; err != nil {
+		return nil, nil, err
+	}
+
+	result := database.DB.Unscoped().Delete(&stored)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(stored.ExpiresAt) {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(stored.Data), &session); err != nil {
+		return nil, nil, err
+	}
+	return &stored, &session, nil
+}
+
+// webauthnUnavailable is the response when the relying party is not configured
+func webauthnUnavailable(c *fiber.Ctx) error {
+	return c.Status(503).JSON(fiber.Map{
+		"success": false,
+		"message": "Passkeys are not configured",
+	})
+}
+
+// WebAuthnRegisterBegin returns the credential creation options for
+// navigator.credentials.create() and a session_token for the finish call
+func WebAuthnRegisterBegin(c *fiber.Ctx) error {
+	w := getWebAuthn()
+	if w == nil {
+		return webauthnUnavailable(c)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	wu, err := loadWebAuthnUser(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	// exclude existing passkeys so the same authenticator is not registered twice
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(wu.credentials))
+	for _, credential := range wu.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, session, err := w.BeginRegistration(wu,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start passkey registration",
+		})
+	}
+
+	token, err := saveWebAuthnSession(ceremonyRegistration, userID, session)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start passkey registration",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Passkey registration started",
+		"data": fiber.Map{
+			"session_token": token,
+			"options":       options,
+		},
+	})
+}
+
+// WebAuthnRegisterFinish verifies the attestation returned by the
+// authenticator and stores the new credential
+func WebAuthnRegisterFinish(c *fiber.Ctx) error {
+	type Req struct {
+		SessionToken string          `json:"session_token"`
+		Name         string          `json:"name"`
+		Credential   json.RawMessage `json:"credential"`
+	}
+
+	w := getWebAuthn()
+	if w == nil {
+		return webauthnUnavailable(c)
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.SessionToken == "" || len(req.Credential) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	stored, session, err := consumeWebAuthnSession(ceremonyRegistration, req.SessionToken)
+	if err != nil || stored.UserID != userID {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired registration session",
+		})
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid credential",
+		})
+	}
+
+	wu, err := loadWebAuthnUser(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	credential, err := w.CreateCredential(wu, *session, parsed)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Passkey verification failed",
+		})
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	record := models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            strings.TrimSpace(req.Name),
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if record.Name == "" {
+		record.Name = "Passkey"
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&record).Error; err != nil {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "Passkey already registered",
+		})
+	}
+	audit.Success(c, audit.ActionPasskeyRegister, userID, map[string]interface{}{
+		"credential_id": record.ID,
+		"name":          record.Name,
+	})
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "Passkey registered",
+		"data":    record,
+	})
+}
+
+// WebAuthnLoginBegin returns assertion options for a discoverable
+// (username-less) passkey login and a session_token for the finish call
+func WebAuthnLoginBegin(c *fiber.Ctx) error {
+	w := getWebAuthn()
+	if w == nil {
+		return webauthnUnavailable(c)
+	}
+
+	options, session, err := w.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start passkey login",
+		})
+	}
+
+	token, err := saveWebAuthnSession(ceremonyLogin, 0, session)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start passkey login",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Passkey login started",
+		"data": fiber.Map{
+			"session_token": token,
+			"options":       options,
+		},
+	})
+}
+
+// WebAuthnLoginFinish verifies the assertion and issues the same tokens as
+// the other login endpoints. A user-verified passkey already combines
+// possession and a PIN/biometric, so no TOTP challenge follows.
+func WebAuthnLoginFinish(c *fiber.Ctx) error {
+	type Req struct {
+		SessionToken string          `json:"session_token"`
+		Credential   json.RawMessage `json:"credential"`
+	}
+
+	w := getWebAuthn()
+	if w == nil {
+		return webauthnUnavailable(c)
+	}
+
+	var req Req
+	if err := c.BodyParser(&req); err != nil || req.SessionToken == "" || len(req.Credential) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid input",
+		})
+	}
+
+	_, session, err := consumeWebAuthnSession(ceremonyLogin, req.SessionToken)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired login session",
+		})
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid credential",
+		})
+	}
+
+	// the authenticator tells us who the user is through the user handle
+	var wu *webauthnUser
+	lookup := func(rawID, userHandle []byte) (webauthn.User, error) {
+		if len(userHandle) != 8 {
+			return nil, errors.New("unknown user handle")
+		}
+		u, err := loadWebAuthnUser(c.UserContext(), uint(binary.BigEndian.Uint64(userHandle)))
+		if err != nil {
+			return nil, err
+		}
+		wu = u
+		return u, nil
+	}
+
+	credential, err := w.ValidateDiscoverableLogin(lookup, *session, parsed)
+	if err != nil || wu == nil {
+		audit.Failure(c, audit.ActionLogin, 0, "passkey_verification_failed", map[string]interface{}{"method": "webauthn"})
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Passkey verification failed",
+		})
+	}
+	if credential.Authenticator.CloneWarning {
+		log.Printf("⚠️  Possible cloned passkey for user %d", wu.user.ID)
+		audit.Failure(c, audit.ActionLogin, wu.user.ID, "passkey_clone_warning", map[string]interface{}{"method": "webauthn"})
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Passkey verification failed",
+		})
+	}
+
+	now := time.Now()
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", wu.user.ID, credential.ID).
+		Updates(map[string]interface{}{
+			"sign_count":   credential.Authenticator.SignCount,
+			"backup_state": credential.Flags.BackupState,
+			"last_used_at": now,
+		}).Error; err != nil {
+		log.Printf("⚠️  Passkey sign count update failed for user %d: %v", wu.user.ID, err)
+	}
+
+	pair, err := issueTokenPair(c, wu.user, "webauthn")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Login successful",
+		"data":    loginResponse(wu.user, pair),
+	})
+}
+
+// ListWebAuthnCredentials returns the passkeys of the current user
+func ListWebAuthnCredentials(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var credentials []models.WebAuthnCredential
+	if err := database.DB.WithContext(c.UserContext()).Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Passkeys retrieved",
+		"data":    credentials,
+	})
+}
+
+// DeleteWebAuthnCredential removes one passkey of the current user
+func DeleteWebAuthnCredential(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	result := database.DB.WithContext(c.UserContext()).Unscoped().
+		Where("id = ? AND user_id = ?", c.Params("id"), userID).
+		Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Passkey not found",
+		})
+	}
+	audit.Success(c, audit.ActionPasskeyDelete, userID, map[string]interface{}{"credential_id": c.Params("id")})
+
+	return c.Status(200).JSON(fiber.Map{
+		"success": true,
+		"message": "Passkey removed",
+	})
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"Auth/models"
+	"Auth/tenancy"
 	"log"
 	"os"
 
@@ -23,6 +24,11 @@ func Connect() {
 		log.Fatal("❌ Failed to connect to database:", err)
 	}
 
+	// Scope User, Company, Job and JobType queries to the request's tenant
+	if err := tenancy.Register(DB); err != nil {
+		log.Fatal("❌ Failed to register tenant scoping:", err)
+	}
+
 	// Auto migrate models
 	AutoMigrate()
 }
@@ -46,11 +52,80 @@ func Close() {
 func AutoMigrate() {
 	// Add your models here
 	DB.AutoMigrate(
+		&models.Tenant{},
 		&models.User{},
 		&models.Role{},
+		&models.Permission{},
 		&models.User_Details{},
 		&models.JobType{},
 		&models.Job{},
 		&models.Company{},
+		&models.CompanyMember{},
+		&models.CompanyInvitation{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.TOTPFactor{},
+		&models.RecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnSession{},
+		&models.PasswordReset{},
+		&models.MagicLink{},
+		&models.LoginThrottle{},
+		&models.LockoutEvent{},
+		&models.UserIdentity{},
+		&models.Impersonation{},
+		&models.ImpersonationRequest{},
+		&models.AuditEvent{},
+		&models.APIKey{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthConsent{},
+		&models.OAuthRefreshToken{},
+		&models.OAuthAccessToken{},
 	)
+	dropLegacyIndexes()
+	protectAuditLog()
+}
+
+// auditLogGuard makes audit_events append-only in the database itself, so
+// raw SQL and other clients cannot rewrite history either. Purging old
+// events needs a superuser or the table owner disabling the triggers.
+const auditLogGuard = `
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_events_no_change ON audit_events;
+CREATE TRIGGER audit_events_no_change BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+`
+
+func protectAuditLog() {
+	if err := DB.Exec(auditLogGuard).Error; err != nil {
+		log.Printf("⚠️  Failed to protect the audit log: %v", err)
+	}
+}
+
+// legacyIndexes were replaced by indexes per tenant; AutoMigrate creates
+// the new ones but never drops the old
+var legacyIndexes = map[interface{}][]string{
+	&models.User{}: {"idx_users_firebase_uid", "idx_users_username", "idx_users_email", "idx_users_tenant_id"},
+}
+
+func dropLegacyIndexes() {
+	for model, names := range legacyIndexes {
+		for _, name := range names {
+			if !DB.Migrator().HasIndex(model, name) {
+				continue
+			}
+			if err := DB.Migrator().DropIndex(model, name); err != nil {
+				log.Printf("⚠️  Failed to drop index %s: %v", name, err)
+			}
+		}
+	}
 }
@@ -1,7 +1,13 @@
 // database/seed.go
 package database
 
-import "Auth/models"
+import (
+	"Auth/models"
+	"Auth/tenancy"
+	"context"
+	"log"
+	"slices"
+)
 
 func SeedRoles() {
 	roles := []string{"user", "admin"}
@@ -13,3 +19,56 @@ func SeedRoles() {
 		}
 	}
 }
+
+// SeedPermissions creates missing permissions. A newly created permission is
+// granted to the built-in roles per models.DefaultRolePermissions, so
+// permissions an admin later took away are not handed back on restart.
+func SeedPermissions() {
+	for _, p := range models.DefaultPermissions {
+		var permission models.Permission
+		if err := DB.Where("name = ?", p.Name).First(&permission).Error; err == nil {
+			continue
+		}
+
+		permission = models.Permission{Name: p.Name, Description: p.Description}
+		if err := DB.Create(&permission).Error; err != nil {
+			log.Printf("⚠️  Failed to seed permission %s: %v", p.Name, err)
+			continue
+		}
+
+		for roleName, names := range models.DefaultRolePermissions {
+			if !slices.Contains(names, p.Name) {
+				continue
+			}
+			var role models.Role
+			if err := DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+				continue
+			}
+			if err := DB.Model(&role).Association("Permissions").Append(&permission); err != nil {
+				log.Printf("⚠️  Failed to grant permission %s to role %s: %v", p.Name, roleName, err)
+			}
+		}
+	}
+}
+
+// SeedTenants creates the default tenant and assigns rows created before
+// multi-tenancy to it
+func SeedTenants() {
+	ctx := tenancy.System(context.Background())
+
+	tenant := models.Tenant{Slug: tenancy.DefaultSlug()}
+	if err := DB.WithContext(ctx).Where("slug = ?", tenant.Slug).
+		Attrs(models.Tenant{Name: "Default", Status: models.StatusActive}).
+		FirstOrCreate(&tenant).Error; err != nil {
+		log.Printf("⚠️  Failed to seed tenant %s: %v", tenant.Slug, err)
+		return
+	}
+
+	for _, model := range []interface{}{&models.User{}, &models.Company{}, &models.Job{}, &models.JobType{}} {
+		if err := DB.WithContext(ctx).Model(model).Unscoped().
+			Where("tenant_id IS NULL OR tenant_id = 0").
+			Update("tenant_id", tenant.ID).Error; err != nil {
+			log.Printf("⚠️  Failed to assign %T rows to tenant %s: %v", model, tenant.Slug, err)
+		}
+	}
+}
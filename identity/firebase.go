@@ -0,0 +1,168 @@
+package identity
+
+import (
+	"Auth/firebase"
+	"context"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// FirebaseProvider is backed by Firebase Authentication
+type FirebaseProvider struct {
+	client *auth.Client
+}
+
+// NewFirebaseProvider initializes the Firebase app (needs SERVICE_ACCOUNT_JSON)
+func NewFirebaseProvider() *FirebaseProvider {
+	firebase.InitFirebase()
+	return &FirebaseProvider{client: firebase.GetAuthClient()}
+}
+
+func (p *FirebaseProvider) Name() string {
+	return "firebase"
+}
+
+func (p *FirebaseProvider) CreateUser(ctx context.Context, params UserToCreate) (*UserRecord, error) {
+	toCreate := (&auth.UserToCreate{}).
+		Email(params.Email).
+		EmailVerified(params.EmailVerified)
+	if params.Password != "" {
+		toCreate = toCreate.Password(params.Password)
+	}
+	if params.DisplayName != "" {
+		toCreate = toCreate.DisplayName(params.DisplayName)
+	}
+
+	user, err := p.client.CreateUser(ctx, toCreate)
+	if err != nil {
+		return nil, mapFirebaseError(err)
+	}
+	return fromFirebaseUser(user), nil
+}
+
+func (p *FirebaseProvider) GetUser(ctx context.Context, uid string) (*UserRecord, error) {
+	user, err := p.client.GetUser(ctx, uid)
+	if err != nil {
+		return nil, mapFirebaseError(err)
+	}
+	return fromFirebaseUser(user), nil
+}
+
+func (p *FirebaseProvider) UpdateUser(ctx context.Context, uid string, params UserToUpdate) (*UserRecord, error) {
+	toUpdate := &auth.UserToUpdate{}
+	if params.Email != nil {
+		toUpdate = toUpdate.Email(*params.Email)
+	}
+	if params.Password != nil {
+		toUpdate = toUpdate.Password(*params.Password)
+	}
+	if params.DisplayName != nil {
+		toUpdate = toUpdate.DisplayName(*params.DisplayName)
+	}
+	if params.EmailVerified != nil {
+		toUpdate = toUpdate.EmailVerified(*params.EmailVerified)
+	}
+	if params.Disabled != nil {
+		toUpdate = toUpdate.Disabled(*params.Disabled)
+	}
+	if len(params.ProvidersToDelete) > 0 {
+		toUpdate = toUpdate.ProvidersToDelete(params.ProvidersToDelete)
+	}
+
+	user, err := p.client.UpdateUser(ctx, uid, toUpdate)
+	if err != nil {
+		return nil, mapFirebaseError(err)
+	}
+	return fromFirebaseUser(user), nil
+}
+
+func (p *FirebaseProvider) DeleteUser(ctx context.Context, uid string) error {
+	return mapFirebaseError(p.client.DeleteUser(ctx, uid))
+}
+
+func (p *FirebaseProvider) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
+	token, err := p.client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	if err != nil {
+		return nil, mapFirebaseError(err)
+	}
+	return fromFirebaseToken(token), nil
+}
+
+func (p *FirebaseProvider) SetCustomUserClaims(ctx context.Context, uid string, claims map[string]interface{}) error {
+	return mapFirebaseError(p.client.SetCustomUserClaims(ctx, uid, claims))
+}
+
+func (p *FirebaseProvider) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	return mapFirebaseError(p.client.RevokeRefreshTokens(ctx, uid))
+}
+
+func (p *FirebaseProvider) CreateSessionCookie(ctx context.Context, idToken string, expiresIn time.Duration) (string, error) {
+	cookie, err := p.client.SessionCookie(ctx, idToken, expiresIn)
+	if err != nil {
+		return "", mapFirebaseError(err)
+	}
+	return cookie, nil
+}
+
+func (p *FirebaseProvider) VerifySessionCookie(ctx context.Context, cookie string) (*Token, error) {
+	token, err := p.client.VerifySessionCookieAndCheckRevoked(ctx, cookie)
+	if err != nil {
+		return nil, mapFirebaseError(err)
+	}
+	return fromFirebaseToken(token), nil
+}
+
+func fromFirebaseUser(user *auth.UserRecord) *UserRecord {
+	record := &UserRecord{
+		UID:           user.UID,
+		Email:         user.Email,
+		DisplayName:   user.DisplayName,
+		EmailVerified: user.EmailVerified,
+		Disabled:      user.Disabled,
+		CustomClaims:  user.CustomClaims,
+	}
+	for _, info := range user.ProviderUserInfo {
+		record.ProviderUserInfo = append(record.ProviderUserInfo, ProviderInfo{
+			ProviderID: info.ProviderID,
+			UID:        info.UID,
+			Email:      info.Email,
+		})
+	}
+	return record
+}
+
+func fromFirebaseToken(token *auth.Token) *Token {
+	email, _ := token.Claims["email"].(string)
+	emailVerified, _ := token.Claims["email_verified"].(bool)
+	return &Token{
+		UID:            token.UID,
+		Email:          email,
+		EmailVerified:  emailVerified,
+		SignInProvider: token.Firebase.SignInProvider,
+		IssuedAt:       token.IssuedAt,
+		Expires:        token.Expires,
+		Claims:         token.Claims,
+	}
+}
+
+// mapFirebaseError translates the Firebase error codes callers care about
+func mapFirebaseError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case auth.IsIDTokenRevoked(err), auth.IsSessionCookieRevoked(err):
+		return ErrTokenRevoked
+	case auth.IsUserDisabled(err):
+		return ErrUserDisabled
+	case auth.IsIDTokenInvalid(err), auth.IsIDTokenExpired(err),
+		auth.IsSessionCookieInvalid(err), auth.IsSessionCookieExpired(err):
+		return ErrInvalidToken
+	case auth.IsUserNotFound(err):
+		return ErrUserNotFound
+	case auth.IsEmailAlreadyExists(err):
+		return ErrEmailExists
+	default:
+		return err
+	}
+}
@@ -0,0 +1,371 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const localIssuer = "local-identity"
+
+// localSessionAudience keeps session cookies from being accepted as ID tokens
+const localSessionAudience = localIssuer + ":session"
+
+// ErrInvalidCredentials is returned by LocalProvider.SignInWithPassword
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// LocalProvider is an in-process identity provider for development and
+// tests. It keeps users in memory (optionally mirrored to a JSON file) and
+// issues/verifies its own HS256 ID tokens, so no network is needed.
+type LocalProvider struct {
+	mu       sync.RWMutex
+	users    map[string]*localUser // by uid
+	secret   []byte
+	tokenTTL time.Duration
+	file     string
+}
+
+type localUser struct {
+	Record       UserRecord `json:"record"`
+	PasswordHash string     `json:"password_hash"`
+	ValidSince   int64      `json:"valid_since"` // ID tokens issued before this are revoked
+}
+
+// NewLocalProvider reads IDENTITY_LOCAL_SECRET (random when unset, so tokens
+// do not survive a restart) and IDENTITY_LOCAL_FILE (persistence, optional)
+func NewLocalProvider() *LocalProvider {
+	p := &LocalProvider{
+		users:    map[string]*localUser{},
+		secret:   []byte(os.Getenv("IDENTITY_LOCAL_SECRET")),
+		tokenTTL: time.Hour,
+		file:     os.Getenv("IDENTITY_LOCAL_FILE"),
+	}
+
+	if len(p.secret) == 0 {
+		p.secret = make([]byte, 32)
+		if _, err := rand.Read(p.secret); err != nil {
+			log.Fatalf("❌ Local identity secret: %v", err)
+		}
+	}
+
+	if p.file != "" {
+		if data, err := os.ReadFile(p.file); err == nil {
+			if err := json.Unmarshal(data, &p.users); err != nil {
+				log.Fatalf("❌ Local identity store %s: %v", p.file, err)
+			}
+		}
+	}
+
+	return p
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) CreateUser(ctx context.Context, params UserToCreate) (*UserRecord, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	email := strings.ToLower(params.Email)
+	if p.findByEmail(email) != nil {
+		return nil, ErrEmailExists
+	}
+
+	uid, err := newLocalUID()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &localUser{
+		Record: UserRecord{
+			UID:           uid,
+			Email:         email,
+			DisplayName:   params.DisplayName,
+			EmailVerified: params.EmailVerified,
+			ProviderUserInfo: []ProviderInfo{
+				{ProviderID: "password", UID: email, Email: email},
+			},
+		},
+	}
+	if params.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = string(hash)
+	}
+
+	p.users[uid] = user
+	p.save()
+
+	record := user.Record
+	return &record, nil
+}
+
+func (p *LocalProvider) GetUser(ctx context.Context, uid string) (*UserRecord, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	user, ok := p.users[uid]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	record := user.Record
+	return &record, nil
+}
+
+func (p *LocalProvider) UpdateUser(ctx context.Context, uid string, params UserToUpdate) (*UserRecord, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	user, ok := p.users[uid]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if params.Email != nil {
+		email := strings.ToLower(*params.Email)
+		if other := p.findByEmail(email); other != nil && other != user {
+			return nil, ErrEmailExists
+		}
+		user.Record.Email = email
+	}
+	if params.Password != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*params.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = string(hash)
+	}
+	if params.DisplayName != nil {
+		user.Record.DisplayName = *params.DisplayName
+	}
+	if params.EmailVerified != nil {
+		user.Record.EmailVerified = *params.EmailVerified
+	}
+	if params.Disabled != nil {
+		user.Record.Disabled = *params.Disabled
+	}
+	if len(params.ProvidersToDelete) > 0 {
+		var kept []ProviderInfo
+		for _, info := range user.Record.ProviderUserInfo {
+			if !slices.Contains(params.ProvidersToDelete, info.ProviderID) {
+				kept = append(kept, info)
+			}
+		}
+		user.Record.ProviderUserInfo = kept
+	}
+	p.save()
+
+	record := user.Record
+	return &record, nil
+}
+
+func (p *LocalProvider) DeleteUser(ctx context.Context, uid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.users[uid]; !ok {
+		return ErrUserNotFound
+	}
+	delete(p.users, uid)
+	p.save()
+	return nil
+}
+
+func (p *LocalProvider) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
+	return p.verify(idToken, localIssuer)
+}
+
+// verify checks a token signed by this provider for the given audience:
+// ID tokens or session cookies
+func (p *LocalProvider) verify(tokenString, audience string) (*Token, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return p.secret, nil
+	},
+		jwt.WithIssuer(localIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	uid, _ := claims["sub"].(string)
+	issuedAt, _ := claims.GetIssuedAt()
+	expires, _ := claims.GetExpirationTime()
+
+	p.mu.RLock()
+	user, ok := p.users[uid]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if user.Record.Disabled {
+		return nil, ErrUserDisabled
+	}
+	if issuedAt == nil || issuedAt.Unix() < user.ValidSince {
+		return nil, ErrTokenRevoked
+	}
+
+	email, _ := claims["email"].(string)
+	emailVerified, _ := claims["email_verified"].(bool)
+	signInProvider, _ := claims["sign_in_provider"].(string)
+	return &Token{
+		UID:            uid,
+		Email:          email,
+		EmailVerified:  emailVerified,
+		SignInProvider: signInProvider,
+		IssuedAt:       issuedAt.Unix(),
+		Expires:        expires.Unix(),
+		Claims:         claims,
+	}, nil
+}
+
+func (p *LocalProvider) CreateSessionCookie(ctx context.Context, idToken string, expiresIn time.Duration) (string, error) {
+	if expiresIn < 5*time.Minute || expiresIn > 14*24*time.Hour {
+		return "", fmt.Errorf("session cookie duration must be between 5 minutes and 2 weeks")
+	}
+	token, err := p.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for k, v := range token.Claims {
+		claims[k] = v
+	}
+	claims["aud"] = localSessionAudience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(expiresIn).Unix()
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.secret)
+}
+
+func (p *LocalProvider) VerifySessionCookie(ctx context.Context, cookie string) (*Token, error) {
+	return p.verify(cookie, localSessionAudience)
+}
+
+func (p *LocalProvider) SetCustomUserClaims(ctx context.Context, uid string, claims map[string]interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	user, ok := p.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Record.CustomClaims = claims
+	p.save()
+	return nil
+}
+
+func (p *LocalProvider) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	user, ok := p.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.ValidSince = time.Now().Unix()
+	p.save()
+	return nil
+}
+
+// SignInWithPassword plays the role of the Firebase client SDK: it checks
+// the credentials and returns an ID token the API endpoints accept.
+func (p *LocalProvider) SignInWithPassword(ctx context.Context, email, password string) (string, error) {
+	p.mu.RLock()
+	user := p.findByEmail(strings.ToLower(email))
+	p.mu.RUnlock()
+
+	if user == nil || user.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return "", ErrInvalidCredentials
+	}
+	if user.Record.Disabled {
+		return "", ErrUserDisabled
+	}
+
+	return p.IssueIDToken(user.Record.UID, "password")
+}
+
+// IssueIDToken signs an ID token for an existing user, as if they had just
+// signed in with the given provider (password, google.com, ...)
+func (p *LocalProvider) IssueIDToken(uid, signInProvider string) (string, error) {
+	p.mu.RLock()
+	user, ok := p.users[uid]
+	p.mu.RUnlock()
+	if !ok {
+		return "", ErrUserNotFound
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	// custom claims first so they can never override the standard ones
+	for k, v := range user.Record.CustomClaims {
+		claims[k] = v
+	}
+	claims["iss"] = localIssuer
+	claims["aud"] = localIssuer
+	claims["sub"] = uid
+	claims["email"] = user.Record.Email
+	claims["email_verified"] = user.Record.EmailVerified
+	claims["sign_in_provider"] = signInProvider
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(p.tokenTTL).Unix()
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.secret)
+}
+
+// findByEmail expects the caller to hold the lock
+func (p *LocalProvider) findByEmail(email string) *localUser {
+	for _, user := range p.users {
+		if user.Record.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+// save mirrors the store to IDENTITY_LOCAL_FILE; the caller holds the lock
+func (p *LocalProvider) save() {
+	if p.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(p.users, "", "  ")
+	if err != nil {
+		log.Printf("⚠️  Local identity store: %v", err)
+		return
+	}
+	if err := os.WriteFile(p.file, data, 0600); err != nil {
+		log.Printf("⚠️  Local identity store: %v", err)
+	}
+}
+
+func newLocalUID() (string, error) {
+	b := make([]byte, 14)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
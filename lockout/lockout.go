@@ -0,0 +1,286 @@
+package lockout
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event names stored in models.LockoutEvent
+const (
+	EventBackoff   = "backoff"
+	EventLocked    = "locked"
+	EventIPBlocked = "ip_blocked"
+	EventUnlocked  = "unlocked"
+)
+
+// Policy controls backoff and lockout; failures older than Window are forgotten
+type Policy struct {
+	BackoffThreshold        int // account failures from one IP before backoff starts
+	LockoutThreshold        int // account failures from one IP before a lockout
+	AccountBackoffThreshold int // account failures from all IPs before backoff starts; never locks
+	IPBackoffThreshold      int // IP failures before backoff starts (higher: NAT, offices)
+	BackoffBase             time.Duration
+	BackoffMax              time.Duration
+	LockoutDuration         time.Duration
+	Window                  time.Duration
+}
+
+var (
+	policy     *Policy
+	policyOnce sync.Once
+)
+
+// loadPolicy reads LOGIN_BACKOFF_THRESHOLD (3), LOGIN_LOCKOUT_THRESHOLD (10),
+// LOGIN_ACCOUNT_BACKOFF_THRESHOLD (10), LOGIN_IP_BACKOFF_THRESHOLD (20),
+// LOGIN_BACKOFF_MAX_SECONDS (900), LOGIN_LOCKOUT_MINUTES (30) and
+// LOGIN_FAILURE_WINDOW_MINUTES (60)
+func loadPolicy() *Policy {
+	policyOnce.Do(func() {
+		policy = &Policy{
+			BackoffThreshold:        envInt("LOGIN_BACKOFF_THRESHOLD", 3),
+			LockoutThreshold:        envInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			AccountBackoffThreshold: envInt("LOGIN_ACCOUNT_BACKOFF_THRESHOLD", 10),
+			IPBackoffThreshold:      envInt("LOGIN_IP_BACKOFF_THRESHOLD", 20),
+			BackoffBase:             time.Second,
+			BackoffMax:              time.Duration(envInt("LOGIN_BACKOFF_MAX_SECONDS", 900)) * time.Second,
+			LockoutDuration:         time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 30)) * time.Minute,
+			Window:                  time.Duration(envInt("LOGIN_FAILURE_WINDOW_MINUTES", 60)) * time.Minute,
+		}
+	})
+	return policy
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// IPKey, AccountKey and EmailKey build throttle subjects
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+func AccountKey(userID uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func EmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// AccountIPKey scopes an account (or email) key to one client IP. Only this
+// counter locks, so guessing from one address cannot lock the owner out
+// everywhere; the account-wide counter backs off guesses spread over many
+// addresses, and the IP key slows a guesser down across accounts.
+func AccountIPKey(accountKey, ip string) string {
+	return accountKey + "|" + IPKey(ip)
+}
+
+// accountKeys matches every counter of an account: the account-wide one
+// and those per IP
+func accountKeys(tx *gorm.DB, userID uint) *gorm.DB {
+	key := AccountKey(userID)
+	return tx.Where("throttle_key = ? OR throttle_key LIKE ?", key, key+"|%")
+}
+
+// backoff doubles per failure past the threshold: 1s, 2s, 4s, ... up to BackoffMax
+func (p *Policy) backoff(failures, threshold int) time.Duration {
+	exponent := failures - threshold
+	if exponent < 0 {
+		return 0
+	}
+	if exponent > 20 {
+		return p.BackoffMax
+	}
+	delay := p.BackoffBase << uint(exponent)
+	if delay > p.BackoffMax {
+		return p.BackoffMax
+	}
+	return delay
+}
+
+// Block describes why a request is refused
+type Block struct {
+	Until  time.Time
+	Locked bool // account lockout rather than backoff
+}
+
+// Check returns the longest active block among the given subjects, or nil
+func Check(keys ...string) (*Block, error) {
+	var rows []models.LoginThrottle
+	if err := database.DB.Where("throttle_key IN ?", keys).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var block *Block
+	consider := func(until *time.Time, locked bool) {
+		if until != nil && until.After(now) && (block == nil || until.After(block.Until)) {
+			block = &Block{Until: *until, Locked: locked}
+		}
+	}
+	for _, row := range rows {
+		consider(row.LockedUntil, true)
+		consider(row.BlockedUntil, false)
+	}
+	return block, nil
+}
+
+// RecordFailure counts a failed attempt for the client IP and, when known,
+// for the account: from that IP (AccountIPKey, may lock) and from all IPs
+// (backoff only). userID is nil for emails that do not belong to an account.
+func RecordFailure(ip, accountKey string, userID *uint) error {
+	p := loadPolicy()
+
+	if err := recordFailure(IPKey(ip), nil, ip, p.IPBackoffThreshold, 0, EventIPBlocked); err != nil {
+		return err
+	}
+	if accountKey == "" {
+		return nil
+	}
+	if err := recordFailure(AccountIPKey(accountKey, ip), userID, ip, p.BackoffThreshold, p.LockoutThreshold, EventBackoff); err != nil {
+		return err
+	}
+	return recordFailure(accountKey, userID, ip, p.AccountBackoffThreshold, 0, EventBackoff)
+}
+
+// RecordRequest counts a request on subjects that only need slowing down,
+// never a lockout (e.g. "password_reset:user:42")
+func RecordRequest(ipKey, accountKey string, userID *uint, ip string) error {
+	p := loadPolicy()
+
+	if err := recordFailure(ipKey, nil, ip, p.IPBackoffThreshold, 0, EventIPBlocked); err != nil {
+		return err
+	}
+	if accountKey == "" {
+		return nil
+	}
+	return recordFailure(accountKey, userID, ip, p.BackoffThreshold, 0, EventBackoff)
+}
+
+// recordFailure increments the counter atomically and applies backoff or a
+// lockout (lockoutThreshold 0 disables lockout, as for IPs). backoffEvent is
+// recorded once, when backoff starts.
+func recordFailure(key string, userID *uint, ip string, backoffThreshold, lockoutThreshold int, backoffEvent string) error {
+	p := loadPolicy()
+	now := time.Now()
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// failures outside the window start a new count, and so does the
+		// first failure after a lockout expired: with a window longer than
+		// the lockout it would otherwise lock the account again at once
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "throttle_key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures": gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? OR login_throttles.locked_until <= ? "+
+					"THEN 1 ELSE login_throttles.failures + 1 END",
+					now.Add(-p.Window), now),
+				"locked_until":    gorm.Expr("CASE WHEN login_throttles.locked_until <= ? THEN NULL ELSE login_throttles.locked_until END", now),
+				"last_failure_at": now,
+				"updated_at":      now,
+			}),
+		}).Create(&models.LoginThrottle{
+			ThrottleKey:   key,
+			UserID:        userID,
+			Failures:      1,
+			LastFailureAt: now,
+		}).Error; err != nil {
+			return err
+		}
+
+		var row models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("throttle_key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		var event string
+		var until time.Time
+		switch {
+		case lockoutThreshold > 0 && row.Failures >= lockoutThreshold:
+			if row.LockedUntil == nil || !row.LockedUntil.After(now) {
+				event = EventLocked
+			}
+			until = now.Add(p.LockoutDuration)
+			row.LockedUntil = &until
+		case row.Failures >= backoffThreshold:
+			until = now.Add(p.backoff(row.Failures, backoffThreshold))
+			row.BlockedUntil = &until
+			if row.Failures == backoffThreshold {
+				event = backoffEvent
+			}
+		default:
+			return nil
+		}
+
+		if err := tx.Model(&row).Updates(map[string]interface{}{
+			"blocked_until": row.BlockedUntil,
+			"locked_until":  row.LockedUntil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if event == "" {
+			return nil
+		}
+		return tx.Create(&models.LockoutEvent{
+			UserID:      userID,
+			ThrottleKey: key,
+			IP:          ip,
+			Event:       event,
+			Failures:    row.Failures,
+			Until:       &until,
+		}).Error
+	})
+}
+
+// RecordSuccess clears the account's counter for the IP of a completed
+// login. The counters of other IPs and the account-wide one stay, so the
+// owner signing in does not give a guesser elsewhere a fresh start; they
+// run out with the Window.
+func RecordSuccess(userID uint, ip string) error {
+	return database.DB.Where("throttle_key = ?", AccountIPKey(AccountKey(userID), ip)).Delete(&models.LoginThrottle{}).Error
+}
+
+// Clear removes every counter of the account, e.g. after the owner proved
+// control of the mailbox with a password reset
+func Clear(userID uint) error {
+	return accountKeys(database.DB, userID).Delete(&models.LoginThrottle{}).Error
+}
+
+// ErrNotLocked is returned by Unlock when the account has no failed attempts on record
+var ErrNotLocked = errors.New("account is not locked")
+
+// Unlock lifts a lockout or backoff before it expires and resets the
+// failure counter (admin action)
+func Unlock(userID, actorID uint, ip string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := accountKeys(tx, userID).Delete(&models.LoginThrottle{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotLocked
+		}
+
+		return tx.Create(&models.LockoutEvent{
+			UserID:      &userID,
+			ThrottleKey: AccountKey(userID),
+			IP:          ip,
+			Event:       EventUnlocked,
+			ActorID:     &actorID,
+		}).Error
+	})
+}
@@ -0,0 +1,61 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"ip", IPKey("203.0.113.7"), "ip:203.0.113.7"},
+		{"account", AccountKey(42), "user:42"},
+		{"email is normalized", EmailKey("  Jane@Example.COM "), "email:jane@example.com"},
+		{"account per ip", AccountIPKey(AccountKey(42), "203.0.113.7"), "user:42|ip:203.0.113.7"},
+		{"email per ip", AccountIPKey(EmailKey("jane@example.com"), "::1"), "email:jane@example.com|ip:::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountIPKeySeparatesIPs(t *testing.T) {
+	// a guesser's failures must not count against the owner's own address
+	account := AccountKey(42)
+	if AccountIPKey(account, "198.51.100.1") == AccountIPKey(account, "203.0.113.7") {
+		t.Error("the same account from two IPs shares a counter")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &Policy{BackoffBase: time.Second, BackoffMax: time.Minute}
+
+	tests := []struct {
+		name      string
+		failures  int
+		threshold int
+		want      time.Duration
+	}{
+		{"below threshold", 2, 3, 0},
+		{"at threshold", 3, 3, time.Second},
+		{"doubles", 5, 3, 4 * time.Second},
+		{"capped", 10, 3, time.Minute},
+		{"no overflow", 100, 3, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.backoff(tt.failures, tt.threshold); got != tt.want {
+				t.Errorf("backoff(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
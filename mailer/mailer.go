@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+)
+
+// Message is a single outgoing email; HTML is optional
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers transactional email (verification links, password resets, ...)
+type Mailer interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+var current Mailer
+
+// Init selects the mailer from MAILER: smtp, file, or log (default)
+func Init() {
+	switch mode := os.Getenv("MAILER"); mode {
+	case "", "log":
+		current = NewLogMailer()
+	case "file":
+		current = NewFileMailer()
+	case "smtp":
+		current = NewSMTPMailer()
+	default:
+		log.Fatalf("❌ Unknown MAILER %q (use smtp, file or log)", mode)
+	}
+
+	log.Printf("✅ Mailer: %s", current.Name())
+}
+
+// Get returns the configured mailer, falling back to the log sink
+func Get() Mailer {
+	if current == nil {
+		current = NewLogMailer()
+	}
+	return current
+}
+
+// Set replaces the mailer, e.g. with a FileMailer in tests
+func Set(m Mailer) {
+	current = m
+}
+
+// from returns MAIL_FROM or a placeholder sender
+func from() string {
+	if sender := os.Getenv("MAIL_FROM"); sender != "" {
+		return sender
+	}
+	return "no-reply@localhost"
+}
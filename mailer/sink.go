@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer prints messages to the server log instead of sending them
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Name() string {
+	return "log"
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 To: %s | Subject: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// FileMailer writes every message as an .eml file to MAILER_DIR
+// (default ./mail), so tests and developers can open the links
+type FileMailer struct {
+	mu   sync.Mutex
+	dir  string
+	from string
+}
+
+func NewFileMailer() *FileMailer {
+	dir := os.Getenv("MAILER_DIR")
+	if dir == "" {
+		dir = "mail"
+	}
+	return &FileMailer{dir: dir, from: from()}
+}
+
+func (m *FileMailer) Name() string {
+	return "file"
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := buildMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), recipient)
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0600)
+}
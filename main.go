@@ -8,9 +8,11 @@ import (
 
 	dotenv "Auth/config"
 	"Auth/database"
-	"Auth/firebase"
+	"Auth/identity"
+	"Auth/mailer"
 	"Auth/middleware"
 	"Auth/routes"
+	"Auth/utils"
 	"Auth/validators"
 
 	"github.com/gofiber/fiber/v2"
@@ -33,9 +35,16 @@ func main() {
 	var buildAt = os.Getenv("BUILD_DATE")
 	var startRunAt = time.Now().Format("2006-01-02 15:04:05")
 
+	// refuse to start with password hashing settings that would fail logins
+	if err := utils.CheckPasswordConfig(); err != nil {
+		log.Fatalf("❌ Password hashing settings: %v", err)
+	}
+
 	database.Connect()
-	// create default roles
+	// create default roles, permissions and tenant
 	database.SeedRoles()
+	database.SeedPermissions()
+	database.SeedTenants()
 	myConfig := fiber.Config{
 		AppName: apiName,
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
@@ -88,8 +97,10 @@ func main() {
 	middleware.Setuplogger(app)
 	//ramit
 	app.Use(middleware.RateLimiter())
-	//call firebase init
-	   firebase.InitFirebase()
+	//call identity provider init (Firebase, or local for offline use)
+	identity.Init()
+	//transactional email (smtp, file or log)
+	mailer.Init()
         
     // Check Firebase connection
 
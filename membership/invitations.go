@@ -0,0 +1,187 @@
+package membership
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyMember      = errors.New("user is already a member of this company")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationClosed   = errors.New("invitation was already accepted or revoked")
+	ErrInvitationInvalid  = errors.New("invitation link is invalid or expired")
+	ErrInvitationEmail    = errors.New("invitation was sent to another email address")
+)
+
+// NormalizeEmail is the form invitation emails are stored and compared in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Invite creates an invitation and returns it with the nonce to embed in
+// the emailed link. Pending invitations for the same address are superseded.
+func Invite(ctx context.Context, companyID, inviterID uint, email, role string, ttl time.Duration) (*models.CompanyInvitation, string, error) {
+	if !ValidRole(role) {
+		return nil, "", ErrInvalidRole
+	}
+	email = NormalizeEmail(email)
+
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation := models.CompanyInvitation{
+		CompanyID:   companyID,
+		Email:       email,
+		Role:        role,
+		TokenHash:   utils.HashToken(nonce),
+		InvitedByID: inviterID,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockCompany(tx, companyID); err != nil {
+			return err
+		}
+
+		var members int64
+		if err := tx.Model(&models.CompanyMember{}).
+			Joins("JOIN users ON users.id = company_members.user_id AND users.deleted_at IS NULL").
+			Where("company_members.company_id = ? AND LOWER(users.email) = ?", companyID, email).
+			Count(&members).Error; err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyMember
+		}
+
+		if err := tx.Model(&models.CompanyInvitation{}).
+			Where("company_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", companyID, email).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &invitation, nonce, nil
+}
+
+// findInvitation loads an invitation of the company inside tx
+func findInvitation(tx *gorm.DB, companyID, invitationID uint) (*models.CompanyInvitation, error) {
+	var invitation models.CompanyInvitation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("company_id = ?", companyID).
+		First(&invitation, invitationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// Reissue gives an open invitation a new nonce and expiry, invalidating the
+// link sent before. Expired invitations can be reissued.
+func Reissue(ctx context.Context, companyID, invitationID uint, ttl time.Duration) (*models.CompanyInvitation, string, error) {
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var invitation *models.CompanyInvitation
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if invitation, err = findInvitation(tx, companyID, invitationID); err != nil {
+			return err
+		}
+		if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+			return ErrInvitationClosed
+		}
+
+		invitation.TokenHash = utils.HashToken(nonce)
+		invitation.ExpiresAt = time.Now().Add(ttl)
+		return tx.Model(invitation).Updates(map[string]interface{}{
+			"token_hash": invitation.TokenHash,
+			"expires_at": invitation.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return invitation, nonce, nil
+}
+
+// RevokeInvitation withdraws an open invitation
+func RevokeInvitation(ctx context.Context, companyID, invitationID uint) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invitation, err := findInvitation(tx, companyID, invitationID)
+		if err != nil {
+			return err
+		}
+		if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+			return ErrInvitationClosed
+		}
+		return tx.Model(invitation).Update("revoked_at", time.Now()).Error
+	})
+}
+
+// Accept adds the user to the company of the invitation. The nonce comes
+// from the verified link; the user must own the invited address. Someone
+// who is already a member keeps their current role.
+func Accept(ctx context.Context, invitationID uint, nonce string, user *models.User) (*models.CompanyMember, error) {
+	var member models.CompanyMember
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.CompanyInvitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&invitation, invitationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+		if invitation.TokenHash != utils.HashToken(nonce) ||
+			invitation.Status(time.Now()) != models.InvitationPending {
+			return ErrInvitationInvalid
+		}
+		if NormalizeEmail(user.Email) != invitation.Email {
+			return ErrInvitationEmail
+		}
+
+		if err := lockCompany(tx, invitation.CompanyID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+
+		err := tx.Where("company_id = ? AND user_id = ?", invitation.CompanyID, user.ID).First(&member).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			member = models.CompanyMember{CompanyID: invitation.CompanyID, UserID: user.ID, Role: invitation.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"accepted_at":    now,
+			"accepted_by_id": user.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
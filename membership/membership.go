@@ -0,0 +1,207 @@
+// Package membership decides who may act on a company's profile and jobs,
+// based on models.CompanyMember.
+package membership
+
+import (
+	"Auth/database"
+	"Auth/middleware"
+	"Auth/models"
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotMember   = errors.New("user is not a member of this company")
+	ErrLastOwner   = errors.New("a company must keep at least one owner")
+	ErrInvalidRole = errors.New("member role must be owner, recruiter or viewer")
+)
+
+// Member roles allowed to perform an action
+var (
+	ProfileEditors = []string{models.MemberOwner}
+	TeamManagers   = []string{models.MemberOwner}
+	JobEditors     = []string{models.MemberOwner, models.MemberRecruiter}
+	Readers        = []string{models.MemberOwner, models.MemberRecruiter, models.MemberViewer}
+)
+
+// ValidRole reports whether role is a member role
+func ValidRole(role string) bool {
+	return role == models.MemberOwner || role == models.MemberRecruiter || role == models.MemberViewer
+}
+
+// Role returns the user's role in the company, "" when not a member
+func Role(ctx context.Context, userID, companyID uint) (string, error) {
+	var member models.CompanyMember
+	err := database.DB.WithContext(ctx).Where("company_id = ? AND user_id = ?", companyID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return member.Role, err
+}
+
+// IsAdmin reports whether the authenticated user administers every company
+func IsAdmin(c *fiber.Ctx) bool {
+	return middleware.HasPermission(c, models.PermUserAdmin)
+}
+
+// Authorize reports whether the authenticated user holds one of the allowed
+// member roles in the company. Admins are always allowed. Company API keys
+// (company_id local) never reach another company, whoever their owner is.
+// Returns gorm.ErrRecordNotFound when the company is not on the request's board.
+func Authorize(c *fiber.Ctx, companyID uint, allowed []string) (bool, error) {
+	if keyCompanyID, ok := c.Locals("company_id").(uint); ok && keyCompanyID != companyID {
+		return false, nil
+	}
+
+	ctx := c.UserContext()
+	if err := database.DB.WithContext(ctx).Select("id").First(&models.Company{}, companyID).Error; err != nil {
+		return false, err
+	}
+
+	if IsAdmin(c) {
+		return true, nil
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return false, nil
+	}
+
+	role, err := Role(ctx, userID, companyID)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range allowed {
+		if role == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddOwner makes the user an owner of a newly created company
+func AddOwner(tx *gorm.DB, companyID, userID uint) error {
+	return tx.Create(&models.CompanyMember{
+		CompanyID: companyID,
+		UserID:    userID,
+		Role:      models.MemberOwner,
+	}).Error
+}
+
+// otherOwners counts the owners of the company besides userID. Callers run
+// it in a transaction holding the company row lock.
+func otherOwners(tx *gorm.DB, companyID, userID uint) (int64, error) {
+	var owners int64
+	err := tx.Model(&models.CompanyMember{}).
+		Where("company_id = ? AND role = ? AND user_id <> ?", companyID, models.MemberOwner, userID).
+		Count(&owners).Error
+	return owners, err
+}
+
+// lockCompany serialises membership changes of one company
+func lockCompany(tx *gorm.DB, companyID uint) error {
+	var company models.Company
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&company, companyID).Error
+}
+
+// SetRole changes the role of a member. With create the user is added when
+// not yet a member (admins assigning owners to existing companies).
+func SetRole(ctx context.Context, companyID, userID uint, role string, create bool) (*models.CompanyMember, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	var member models.CompanyMember
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockCompany(tx, companyID); err != nil {
+			return err
+		}
+
+		err := tx.Where("company_id = ? AND user_id = ?", companyID, userID).First(&member).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !create {
+				return ErrNotMember
+			}
+			member = models.CompanyMember{CompanyID: companyID, UserID: userID, Role: role}
+			return tx.Create(&member).Error
+		case err != nil:
+			return err
+		}
+
+		if member.Role == models.MemberOwner && role != models.MemberOwner {
+			owners, err := otherOwners(tx, companyID, userID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return ErrLastOwner
+			}
+		}
+		return tx.Model(&member).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// Remove takes a user out of the company; the last owner cannot leave
+func Remove(ctx context.Context, companyID, userID uint) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockCompany(tx, companyID); err != nil {
+			return err
+		}
+
+		var member models.CompanyMember
+		if err := tx.Where("company_id = ? AND user_id = ?", companyID, userID).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotMember
+			}
+			return err
+		}
+
+		if member.Role == models.MemberOwner {
+			owners, err := otherOwners(tx, companyID, userID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return ErrLastOwner
+			}
+		}
+		return tx.Delete(&member).Error
+	})
+}
+
+// Require answers 403 (404, 500) unless Authorize allows the request. Use as
+//
+//	if ok, err := membership.Require(c, companyID, membership.JobEditors); !ok {
+//		return err
+//	}
+func Require(c *fiber.Ctx, companyID uint, allowed []string) (bool, error) {
+	ok, err := Authorize(c, companyID, allowed)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Company not found",
+		})
+	}
+	if err != nil {
+		return false, c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !ok {
+		return false, c.Status(403).JSON(fiber.Map{
+			"success": false,
+			"message": "You are not allowed to manage this company",
+		})
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package membership
+
+import (
+	"Auth/models"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestValidRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{models.MemberOwner, true},
+		{models.MemberRecruiter, true},
+		{models.MemberViewer, true},
+		{"admin", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			if got := ValidRole(tt.role); got != tt.want {
+				t.Errorf("ValidRole(%q) = %v, want %v", tt.role, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireCompanyAPIKey(t *testing.T) {
+	// an API key bound to company 1 never reaches company 2, even for an
+	// admin owner; the refusal comes before any database lookup
+	app := fiber.New()
+	app.Get("/companies/:id", func(c *fiber.Ctx) error {
+		c.Locals("company_id", uint(1))
+		c.Locals("permissions", map[string]bool{models.PermUserAdmin: true})
+		companyID, _ := c.ParamsInt("id")
+		if ok, err := Require(c, uint(companyID), Readers); !ok {
+			return err
+		}
+		return c.SendStatus(200)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/companies/2", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 403 {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+}
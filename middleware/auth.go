@@ -1,62 +1,282 @@
 package middleware
 
 import (
+	"Auth/accounts"
 	"Auth/database"
-	"Auth/firebase"
+	"Auth/identity"
 	"Auth/models"
+	"Auth/utils"
 	"context"
+	"errors"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+// AuthMechanism names a way a request can prove who it is
+type AuthMechanism string
+
+const (
+	AuthFirebase      AuthMechanism = "firebase"       // identity provider ID token (Firebase or local) as bearer
+	AuthJWT           AuthMechanism = "jwt"            // our own access token (utils.GenerateJWT) as bearer
+	AuthAPIKey        AuthMechanism = "api_key"        // machine key in the X-API-Key header
+	AuthOAuth         AuthMechanism = "oauth"          // access token issued to an OAuth client as bearer
+	AuthSessionCookie AuthMechanism = "session_cookie" // identity provider session cookie, for browsers
+)
+
+// APIKeyHeader carries API keys, so they never mix with bearer tokens
+const APIKeyHeader = "X-API-Key"
+
+// authenticator verifies the credentials of a request and returns the user
+// and the firebase uid they belong to. The returned error message is sent
+// to the client as is.
+type authenticator func(c *fiber.Ctx, token string) (*models.User, string, error)
+
+var authenticators = map[AuthMechanism]authenticator{
+	AuthFirebase:      firebaseAuthenticator,
+	AuthJWT:           jwtAuthenticator,
+	AuthAPIKey:        apiKeyAuthenticator,
+	AuthOAuth:         oauthAuthenticator,
+	AuthSessionCookie: sessionCookieAuthenticator,
+}
+
+// FirebaseAuth accepts Firebase ID tokens only
 func FirebaseAuth() fiber.Handler {
+	return Authenticate(AuthFirebase)
+}
+
+// JWTAuth accepts access tokens issued by this service only
+func JWTAuth() fiber.Handler {
+	return Authenticate(AuthJWT)
+}
+
+// Authenticate accepts a bearer token (or API key) verified by any of the
+// given mechanisms, tried in order, so each route group can choose what it trusts.
+func Authenticate(mechanisms ...AuthMechanism) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(401).JSON(fiber.Map{
-				"success": false,
-				"message": "Missing Authorization header",
-			})
+		bearer, bearerErr := bearerToken(c)
+
+		var lastErr error
+		tried := 0
+		for _, mechanism := range mechanisms {
+			credential := bearer
+			switch mechanism {
+			case AuthAPIKey:
+				credential = c.Get(APIKeyHeader)
+			case AuthSessionCookie:
+				credential = c.Cookies(SessionCookieName)
+			}
+			if credential == "" {
+				continue
+			}
+
+			tried++
+			user, firebaseUID, err := authenticators[mechanism](c, credential)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			// ✅ SET WHAT YOUR HANDLER EXPECTS
+			c.Locals("user_id", user.ID)
+			c.Locals("user", user.User_Details.Name)
+			c.Locals("roles", user.Roles)
+			c.Locals("firebase_uid", firebaseUID)
+			c.Locals("email", user.Email)
+			c.Locals("email_verified", user.EmailVerifiedAt != nil)
+			c.Locals("auth_mechanism", mechanism)
+
+			// the real actor: the admin behind an impersonation token, else the user
+			impersonation, impersonated := c.Locals("impersonation").(*models.Impersonation)
+			c.Locals("impersonated", impersonated)
+			if !impersonated {
+				c.Locals("actor_id", user.ID)
+				return c.Next()
+			}
+			c.Locals("actor_id", impersonation.ActorID)
+			err = c.Next()
+			recordImpersonatedRequest(c, impersonation, err)
+			return err
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(401).JSON(fiber.Map{
-				"success": false,
-				"message": "Invalid Authorization format",
-			})
+		message := "Invalid or expired token"
+		switch {
+		case tried == 0 && bearerErr != nil:
+			message = bearerErr.Error()
+		case tried == 1 && lastErr != nil:
+			message = lastErr.Error()
 		}
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": message,
+		})
+	}
+}
+
+// bearerToken reads the Authorization: Bearer header
+func bearerToken(c *fiber.Ctx) (string, error) {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("Missing Authorization header")
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", errors.New("Invalid Authorization format")
+	}
+	return parts[1], nil
+}
 
-		idToken := parts[1]
+func firebaseAuthenticator(c *fiber.Ctx, idToken string) (*models.User, string, error) {
+	// also rejects tokens minted before RevokeRefreshTokens ("log out everywhere")
+	decoded, err := identity.Get().VerifyIDToken(context.Background(), idToken)
+	if err != nil {
+		if errors.Is(err, identity.ErrTokenRevoked) {
+			return nil, "", errors.New("Firebase token has been revoked")
+		}
+		return nil, "", errors.New("Invalid Firebase token")
+	}
+
+	user, err := identityUser(c, decoded.UID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// an ID token proves the first factor only; users with a second factor
+	// must sign in through the login endpoints and use the tokens they issue
+	enrolled, err := MFAEnrolled(c, user.ID)
+	if err != nil {
+		return nil, "", errors.New("Database error")
+	}
+	if enrolled {
+		return nil, "", errors.New("Two-factor authentication required, sign in to get an access token")
+	}
+
+	touchIdentitySession(c, user.ID)
+	return user, user.FirebaseUID, nil
+}
 
-		client := firebase.GetAuthClient()
-		decoded, err := client.VerifyIDToken(context.Background(), idToken)
+// MFAEnrolled reports whether the user has a confirmed second factor
+func MFAEnrolled(c *fiber.Ctx, userID uint) (bool, error) {
+	var count int64
+	err := database.DB.WithContext(c.UserContext()).Model(&models.TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// identityUser finds the user of an identity-provider account
+func identityUser(c *fiber.Ctx, uid string) (*models.User, error) {
+	// 🔥 Find user in DB by Firebase UID, or the user the account is linked to
+	var user models.User
+	err := database.DB.WithContext(c.UserContext()).
+		Preload("Roles").
+		Preload("User_Details").
+		Where("firebase_uid = ?", uid).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var userID uint
+		if userID, err = accounts.LinkedUserID(c.UserContext(), uid); err == nil {
+			err = database.DB.WithContext(c.UserContext()).
+				Preload("Roles").
+				Preload("User_Details").
+				First(&user, userID).Error
+		}
+	}
+	if err != nil {
+		return nil, errors.New("User not registered")
+	}
+	return &user, nil
+}
+
+func jwtAuthenticator(c *fiber.Ctx, tokenString string) (*models.User, string, error) {
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil {
+		if errors.Is(err, utils.ErrExpiredToken) {
+			return nil, "", errors.New("Token has expired")
+		}
+		return nil, "", errors.New("Invalid token")
+	}
+
+	// Reject tokens revoked by logout
+	var revoked int64
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.RevokedToken{}).
+		Where("jti = ?", claims.ID).
+		Count(&revoked).Error; err != nil || revoked > 0 {
+		return nil, "", errors.New("Token has been revoked")
+	}
+
+	// Reject tokens of a revoked session (logout, device management, password reset)
+	if claims.SessionID != 0 {
+		var session models.Session
+		if err := database.DB.WithContext(c.UserContext()).First(&session, claims.SessionID).Error; err != nil ||
+			session.RevokedAt != nil || session.UserID != claims.UserID {
+			return nil, "", errors.New("Session has been revoked")
+		}
+		touchSession(&session, c.IP())
+	}
+
+	// The token only proves identity; roles are always read fresh
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).
+		Preload("Roles").
+		Preload("User_Details").
+		First(&user, claims.UserID).Error; err != nil {
+		return nil, "", errors.New("User not registered")
+	}
+
+	if claims.Actor != nil {
+		impersonation, err := activeImpersonation(c, claims)
 		if err != nil {
-			return c.Status(401).JSON(fiber.Map{
-				"success": false,
-				"message": "Invalid Firebase token",
-			})
-		}
-
-		// 🔥 Find user in DB by Firebase UID
-		var user models.User
-		if err := database.DB.
-			Where("firebase_uid = ?", decoded.UID).
-			First(&user).Error; err != nil {
-
-			return c.Status(401).JSON(fiber.Map{
-				"success": false,
-				"message": "User not registered",
-			})
-		}
-
-		// ✅ SET WHAT YOUR HANDLER EXPECTS
-		c.Locals("user_id", user.ID)
-		c.Locals("user", user.User_Details.Name)
-		c.Locals("roles", user.Roles)
-		c.Locals("firebase_uid", decoded.UID)
-		c.Locals("email", user.Email)
-		return c.Next()
+			return nil, "", err
+		}
+		c.Locals("impersonation", impersonation)
+	}
+
+	// handlers such as Logout need the jti/sid of the presented token
+	c.Locals("jwt_claims", claims)
+	return &user, user.FirebaseUID, nil
+}
+
+// sessionTouchInterval limits last-seen writes to one per session and minute
+const sessionTouchInterval = time.Minute
+
+// touchIdentitySession records activity of identity provider credentials
+// (ID tokens, session cookies), which are not tied to one of our sessions:
+// the user's latest live session started from the same user agent stands
+// for the device.
+func touchIdentitySession(c *fiber.Ctx, userID uint) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	var session models.Session
+	err := database.DB.WithContext(c.UserContext()).
+		Where("user_id = ? AND user_agent = ? AND revoked_at IS NULL AND expires_at > ?", userID, userAgent, time.Now()).
+		Order("last_seen_at DESC").
+		First(&session).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("⚠️  Session lookup failed for user %d: %v", userID, err)
+		}
+		return
+	}
+	touchSession(&session, c.IP())
+}
+
+// touchSession records activity for the device list
+func touchSession(session *models.Session, ip string) {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval && session.IP == ip {
+		return
+	}
+	if err := database.DB.Model(session).Updates(map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           ip,
+	}).Error; err != nil {
+		log.Printf("⚠️  Session last-seen update failed for session %d: %v", session.ID, err)
 	}
 }
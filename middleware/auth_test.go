@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testResponse is the JSON body handlers and middleware answer with
+type testResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// serve runs one request through handlers and decodes the answer
+func serve(t *testing.T, method string, headers map[string]string, handlers ...fiber.Handler) (int, testResponse) {
+	t.Helper()
+	app := fiber.New()
+	app.Add(method, "/", handlers...)
+
+	req := httptest.NewRequest(method, "/", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+// ok is the protected handler
+func ok(c *fiber.Ctx) error {
+	return c.Status(200).JSON(fiber.Map{"success": true, "message": "ok"})
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-hs256")
+
+	tests := []struct {
+		name        string
+		mechanisms  []AuthMechanism
+		method      string
+		headers     map[string]string
+		wantMessage string
+	}{
+		{
+			name:        "no credentials",
+			mechanisms:  []AuthMechanism{AuthJWT},
+			headers:     nil,
+			wantMessage: "Missing Authorization header",
+		},
+		{
+			name:        "not a bearer token",
+			mechanisms:  []AuthMechanism{AuthJWT},
+			headers:     map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantMessage: "Invalid Authorization format",
+		},
+		{
+			name:        "bearer without token",
+			mechanisms:  []AuthMechanism{AuthJWT},
+			headers:     map[string]string{"Authorization": "Bearer"},
+			wantMessage: "Invalid Authorization format",
+		},
+		{
+			name:        "malformed jwt",
+			mechanisms:  []AuthMechanism{AuthJWT},
+			headers:     map[string]string{"Authorization": "Bearer not.a.jwt"},
+			wantMessage: "Invalid token",
+		},
+		{
+			name:        "malformed api key",
+			mechanisms:  []AuthMechanism{AuthAPIKey},
+			headers:     map[string]string{APIKeyHeader: "not-an-api-key"},
+			wantMessage: "Invalid API key",
+		},
+		{
+			name:        "api key in the authorization header is not an api key",
+			mechanisms:  []AuthMechanism{AuthAPIKey},
+			headers:     map[string]string{"Authorization": "Bearer ak_0123abcd_secret"},
+			wantMessage: "Invalid or expired token",
+		},
+		{
+			name:        "session cookie write without csrf token",
+			mechanisms:  []AuthMechanism{AuthSessionCookie},
+			method:      fiber.MethodPost,
+			headers:     map[string]string{"Cookie": SessionCookieName + "=cookie-value"},
+			wantMessage: "Missing or invalid CSRF token",
+		},
+		{
+			name:        "several mechanisms fail",
+			mechanisms:  []AuthMechanism{AuthAPIKey, AuthJWT},
+			headers:     map[string]string{APIKeyHeader: "bad", "Authorization": "Bearer bad"},
+			wantMessage: "Invalid or expired token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = fiber.MethodGet
+			}
+			status, body := serve(t, method, tt.headers, Authenticate(tt.mechanisms...), ok)
+			if status != 401 || body.Success || body.Message != tt.wantMessage {
+				t.Errorf("got %d %q, want 401 %q", status, body.Message, tt.wantMessage)
+			}
+		})
+	}
+}
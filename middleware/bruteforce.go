@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"Auth/database"
+	"Auth/lockout"
+	"Auth/models"
+	"Auth/utils"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LoginGuard protects credential endpoints: every 401 counts as a failed
+// attempt for the client IP and for the targeted account, from that IP and
+// from all IPs (see lockout.RecordFailure), and blocked subjects get 429
+// before the handler runs. Only the per-IP account counter locks, so nobody
+// can lock the owner out by guessing wrong on purpose. Completed logins clear
+// it in the controller (see issueTokenPair).
+func LoginGuard() fiber.Handler {
+	return bruteForceGuard("")
+}
+
+// RequestGuard is for endpoints that answer the same way for every input
+// (e.g. password reset requests): each request counts, under its own scope,
+// and only backs off. It never locks the account, as it may be the way
+// out of a lockout.
+func RequestGuard(scope string) fiber.Handler {
+	return bruteForceGuard(scope)
+}
+
+func bruteForceGuard(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ip := c.IP()
+		ipKey := lockout.IPKey(ip)
+		accountKey, userID := targetAccount(c)
+		if scope != "" {
+			ipKey = scope + ":" + ipKey
+			if accountKey != "" {
+				accountKey = scope + ":" + accountKey
+			}
+		}
+
+		keys := []string{ipKey}
+		if accountKey != "" {
+			keys = append(keys, accountKey)
+			if scope == "" {
+				keys = append(keys, lockout.AccountIPKey(accountKey, ip))
+			}
+		}
+
+		block, err := lockout.Check(keys...)
+		if err != nil {
+			// fail open: the database being down already breaks logins
+			log.Printf("⚠️  Login throttle check failed: %v", err)
+		} else if block != nil {
+			retryAfter := int(math.Ceil(time.Until(block.Until).Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			// same message for backoff and lockout, and for unknown emails
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"success":     false,
+				"message":     "Too many failed attempts. Please try again later",
+				"retry_after": retryAfter,
+			})
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		var recordErr error
+		if scope != "" {
+			recordErr = lockout.RecordRequest(ipKey, accountKey, userID, ip)
+		} else if c.Response().StatusCode() == fiber.StatusUnauthorized {
+			recordErr = lockout.RecordFailure(ip, accountKey, userID)
+		}
+		if recordErr != nil {
+			log.Printf("⚠️  Login throttle update failed: %v", recordErr)
+		}
+		return nil
+	}
+}
+
+// targetAccount identifies the account a request is aimed at: by email, or
+// by the user of an MFA challenge. ID-token logins cannot name a victim
+// (the token is verified first), so they are only throttled per IP.
+func targetAccount(c *fiber.Ctx) (string, *uint) {
+	var body struct {
+		Email    string `json:"email"`
+		MFAToken string `json:"mfa_token"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return "", nil
+	}
+
+	if body.MFAToken != "" {
+		claims, err := utils.ValidateActionToken(body.MFAToken, utils.PurposeMFA)
+		if err != nil {
+			return "", nil
+		}
+		return lockout.AccountKey(claims.UserID), &claims.UserID
+	}
+
+	email := strings.TrimSpace(body.Email)
+	if email == "" {
+		return "", nil
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).Select("id").Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
+		return lockout.EmailKey(email), nil
+	}
+	return lockout.AccountKey(user.ID), &user.ID
+}
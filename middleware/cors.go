@@ -1,15 +1,24 @@
 package middleware
 
 import (
+	"os"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
 //1. CORS middleware is correclty or not
 func SetupCores(app *fiber.App) {
-	app.Use(cors.New(cors.Config{
+	config := cors.Config{
 		// AllowOrigins: "*",
 		// AllowHeaders: "Origin, Content-Type, Accept, Authorization",
 		// AllowMethods: "GET, POST, PUT, DELETE, PATCH, OPTIONS",
-	}))
+	}
+	// browsers only send session cookies (see session_cookie.go) to other
+	// origins when they are listed, e.g. https://jobs.example.com
+	if origins := os.Getenv("CORS_ALLOW_ORIGINS"); origins != "" {
+		config.AllowOrigins = origins
+		config.AllowCredentials = true
+	}
+	app.Use(cors.New(config))
 }
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireVerifiedEmail blocks sensitive routes until the user confirmed
+// their email address. It is a no-op unless REQUIRE_EMAIL_VERIFICATION=true,
+// and must run after Authenticate.
+func RequireVerifiedEmail() fiber.Handler {
+	enabled := os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+
+	return func(c *fiber.Ctx) error {
+		if !enabled {
+			return c.Next()
+		}
+
+		if verified, _ := c.Locals("email_verified").(bool); verified {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Please verify your email address first",
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// activeImpersonation loads the grant of an impersonation token; tokens of
+// a stopped impersonation are refused even before they expire
+func activeImpersonation(c *fiber.Ctx, claims *utils.JWTClaims) (*models.Impersonation, error) {
+	var impersonation models.Impersonation
+	if err := database.DB.WithContext(c.UserContext()).
+		Where("token_id = ? AND actor_id = ? AND user_id = ? AND ended_at IS NULL",
+			claims.ID, claims.Actor.UserID, claims.UserID).
+		First(&impersonation).Error; err != nil {
+		return nil, errors.New("Impersonation has ended")
+	}
+	return &impersonation, nil
+}
+
+// recordImpersonatedRequest keeps a trail of everything done while
+// impersonating. It runs after the handler, so the status is final.
+func recordImpersonatedRequest(c *fiber.Ctx, impersonation *models.Impersonation, handlerErr error) {
+	status := c.Response().StatusCode()
+	var fiberErr *fiber.Error
+	if errors.As(handlerErr, &fiberErr) {
+		status = fiberErr.Code
+	} else if handlerErr != nil {
+		status = fiber.StatusInternalServerError
+	}
+
+	path := c.OriginalURL()
+	if len(path) > 512 {
+		path = path[:512]
+	}
+	requestID, _ := c.Locals("requestid").(string)
+	if requestID == "" {
+		requestID = c.Get(fiber.HeaderXRequestID)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&models.ImpersonationRequest{
+		ImpersonationID: impersonation.ID,
+		Method:          c.Method(),
+		Path:            path,
+		Status:          status,
+		IP:              c.IP(),
+		RequestID:       requestID,
+	}).Error; err != nil {
+		log.Printf("⚠️  Impersonated request of impersonation %d not recorded: %v", impersonation.ID, err)
+	}
+}
+
+// DenyImpersonation refuses the route to impersonation tokens, for actions
+// only the user themselves may take (deleting the account, signing out
+// devices, changing credentials). Use after Authenticate.
+func DenyImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if impersonated, _ := c.Locals("impersonated").(bool); impersonated {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"message": "Not allowed while impersonating a user",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/utils"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oauthAuthenticator accepts access tokens issued to OAuth clients. Tokens
+// from the authorization code flow act as the user who consented, client
+// credentials tokens as the client's owner; both are limited to their scopes
+// (see RequireScope). Besides the usual locals it sets oauth_claims and scopes.
+func oauthAuthenticator(c *fiber.Ctx, tokenString string) (*models.User, string, error) {
+	claims, err := utils.ValidateOAuthAccessToken(tokenString)
+	if err != nil {
+		if errors.Is(err, utils.ErrExpiredToken) {
+			return nil, "", errors.New("Token has expired")
+		}
+		return nil, "", errors.New("Invalid token")
+	}
+
+	// Reject tokens revoked at the revocation endpoint
+	var revoked int64
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.RevokedToken{}).
+		Where("jti = ?", claims.ID).
+		Count(&revoked).Error; err != nil || revoked > 0 {
+		return nil, "", errors.New("Token has been revoked")
+	}
+
+	// Deleting a client invalidates everything issued to it
+	var client models.OAuthClient
+	if err := database.DB.WithContext(c.UserContext()).Where("client_id = ?", claims.ClientID).First(&client).Error; err != nil {
+		return nil, "", errors.New("OAuth client no longer exists")
+	}
+
+	userID := claims.UserID
+	if userID == 0 {
+		userID = client.OwnerID
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).
+		Preload("Roles").
+		Preload("User_Details").
+		First(&user, userID).Error; err != nil {
+		return nil, "", errors.New("User not registered")
+	}
+
+	c.Locals("oauth_claims", claims)
+	c.Locals("scopes", strings.Fields(claims.Scope))
+	return &user, user.FirebaseUID, nil
+}
@@ -1,14 +1,25 @@
 package middleware
 
 import (
+	"Auth/database"
 	"Auth/models"
+	"Auth/utils"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// RequireRole allows users holding any of the roles. Prefer RequirePermission,
+// which keeps working when admins reshape roles. Must run after Authenticate.
 func RequireRole(requiredRoles ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		roles := c.Locals("roles").([]models.Role)
+		roles, ok := c.Locals("roles").([]models.Role)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": "Unauthorized",
+			})
+		}
 		for _, role := range roles {
 			for _, r := range requiredRoles {
 				if role.Name == r {
@@ -22,3 +33,105 @@ func RequireRole(requiredRoles ...string) fiber.Handler {
 		})
 	}
 }
+
+// RequirePermission allows users whose roles grant all of the permissions
+// (models.Perm*). Must run after Authenticate.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, err := effectivePermissions(c)
+		if err != nil {
+			log.Printf("⚠️  Permission lookup failed: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		if granted == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": "Unauthorized",
+			})
+		}
+
+		for _, permission := range permissions {
+			if !granted[permission] {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"success": false,
+					"message": "Forbidden: missing permission " + permission,
+				})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// HasPermission reports whether the authenticated user's roles grant the
+// permission, for checks that depend on the request body
+func HasPermission(c *fiber.Ctx, permission string) bool {
+	granted, err := effectivePermissions(c)
+	if err != nil {
+		log.Printf("⚠️  Permission lookup failed: %v", err)
+		return false
+	}
+	return granted[permission]
+}
+
+// scopePermissions are the most an API key or OAuth token can do with a
+// scope. Credentials act for their owner but never with more than their
+// scopes, so a key created by an admin does not carry user:admin.
+var scopePermissions = map[string][]string{
+	utils.ScopeJobsWrite:      {models.PermJobCreate, models.PermJobUpdate, models.PermJobDelete},
+	utils.ScopeCompaniesWrite: {models.PermCompanyCreate, models.PermCompanyUpdate, models.PermCompanyDelete},
+}
+
+// effectivePermissions resolves the permissions of the roles set by
+// Authenticate, once per request (cached in the permissions local), limited
+// to the scopes of API keys and OAuth tokens. Returns nil without an
+// authenticated user.
+func effectivePermissions(c *fiber.Ctx) (map[string]bool, error) {
+	if cached, ok := c.Locals("permissions").(map[string]bool); ok {
+		return cached, nil
+	}
+
+	roles, ok := c.Locals("roles").([]models.Role)
+	if !ok {
+		return nil, nil
+	}
+
+	granted := map[string]bool{}
+	if len(roles) > 0 {
+		roleIDs := make([]uint, len(roles))
+		for i, role := range roles {
+			roleIDs[i] = role.ID
+		}
+
+		var names []string
+		if err := database.DB.WithContext(c.UserContext()).Model(&models.Permission{}).
+			Distinct("permissions.name").
+			Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+			Where("role_permissions.role_id IN ?", roleIDs).
+			Pluck("permissions.name", &names).Error; err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			granted[name] = true
+		}
+	}
+
+	mechanism, _ := c.Locals("auth_mechanism").(AuthMechanism)
+	if mechanism == AuthAPIKey || mechanism == AuthOAuth {
+		scoped := map[string]bool{}
+		scopes, _ := c.Locals("scopes").([]string)
+		for _, scope := range scopes {
+			for _, permission := range scopePermissions[scope] {
+				if granted[permission] {
+					scoped[permission] = true
+				}
+			}
+		}
+		granted = scoped
+	}
+
+	c.Locals("permissions", granted)
+	return granted, nil
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"Auth/models"
+	"Auth/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withLocals stands in for Authenticate
+func withLocals(locals map[string]interface{}) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for key, value := range locals {
+			c.Locals(key, value)
+		}
+		return c.Next()
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		locals     map[string]interface{}
+		required   []string
+		wantStatus int
+	}{
+		{
+			name:       "not authenticated",
+			locals:     nil,
+			required:   []string{models.PermJobCreate},
+			wantStatus: 401,
+		},
+		{
+			name: "granted",
+			locals: map[string]interface{}{
+				"roles":       []models.Role{{Name: "user"}},
+				"permissions": map[string]bool{models.PermJobCreate: true},
+			},
+			required:   []string{models.PermJobCreate},
+			wantStatus: 200,
+		},
+		{
+			name: "missing one of several",
+			locals: map[string]interface{}{
+				"roles":       []models.Role{{Name: "user"}},
+				"permissions": map[string]bool{models.PermJobCreate: true},
+			},
+			required:   []string{models.PermJobCreate, models.PermJobDelete},
+			wantStatus: 403,
+		},
+		{
+			name: "user without roles",
+			locals: map[string]interface{}{
+				"roles": []models.Role{},
+			},
+			required:   []string{models.PermJobCreate},
+			wantStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := serve(t, fiber.MethodGet, nil, withLocals(tt.locals), RequirePermission(tt.required...), ok)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		locals     map[string]interface{}
+		wantStatus int
+	}{
+		{
+			name:       "people are not limited by scopes",
+			locals:     map[string]interface{}{"auth_mechanism": AuthJWT},
+			wantStatus: 200,
+		},
+		{
+			name: "api key with the scope",
+			locals: map[string]interface{}{
+				"auth_mechanism": AuthAPIKey,
+				"scopes":         []string{utils.ScopeJobsRead, utils.ScopeJobsWrite},
+			},
+			wantStatus: 200,
+		},
+		{
+			name: "api key without the scope",
+			locals: map[string]interface{}{
+				"auth_mechanism": AuthAPIKey,
+				"scopes":         []string{utils.ScopeJobsRead},
+			},
+			wantStatus: 403,
+		},
+		{
+			name: "oauth token without scopes",
+			locals: map[string]interface{}{
+				"auth_mechanism": AuthOAuth,
+			},
+			wantStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := serve(t, fiber.MethodGet, nil, withLocals(tt.locals), RequireScope(utils.ScopeJobsWrite), ok)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestScopePermissionsNeverGrantAdmin(t *testing.T) {
+	for scope, permissions := range scopePermissions {
+		for _, permission := range permissions {
+			if permission == models.PermUserAdmin {
+				t.Errorf("scope %s grants %s", scope, models.PermUserAdmin)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"Auth/database"
+	"Auth/identity"
+	"Auth/models"
+	"Auth/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Session cookies let browser clients stay signed in without keeping a
+// bearer token in JavaScript (see controllers.CreateSession)
+const (
+	SessionCookieName = "session"    // HttpOnly Firebase session cookie
+	CSRFCookieName    = "csrf_token" // readable copy of the CSRF token for the page's scripts
+	CSRFHeader        = "X-CSRF-Token"
+)
+
+// CSRFToken derives the CSRF token of a session cookie. Other sites can read
+// neither the HttpOnly cookie nor this value, so a matching header proves
+// the request was made by a page of ours.
+func CSRFToken(sessionCookie string) string {
+	return utils.HashToken(sessionCookie)
+}
+
+// SessionCookieID names a session cookie in the revoked token denylist
+func SessionCookieID(sessionCookie string) string {
+	return "session:" + utils.HashToken(sessionCookie)
+}
+
+// SessionCookieAuth accepts Firebase session cookies only, the browser
+// counterpart of FirebaseAuth
+func SessionCookieAuth() fiber.Handler {
+	return Authenticate(AuthSessionCookie)
+}
+
+// sessionCookieAuthenticator verifies the session cookie. Browsers send it
+// with requests started by any site, so requests that change state must
+// also carry the CSRF header.
+func sessionCookieAuthenticator(c *fiber.Ctx, cookie string) (*models.User, string, error) {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+	default:
+		if subtle.ConstantTimeCompare([]byte(c.Get(CSRFHeader)), []byte(CSRFToken(cookie))) != 1 {
+			return nil, "", errors.New("Missing or invalid CSRF token")
+		}
+	}
+
+	decoded, err := identity.Get().VerifySessionCookie(context.Background(), cookie)
+	if err != nil {
+		if errors.Is(err, identity.ErrTokenRevoked) {
+			return nil, "", errors.New("Session has been revoked")
+		}
+		return nil, "", errors.New("Invalid or expired session")
+	}
+
+	// Reject cookies revoked by logout
+	var revoked int64
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.RevokedToken{}).
+		Where("jti = ?", SessionCookieID(cookie)).
+		Count(&revoked).Error; err != nil || revoked > 0 {
+		return nil, "", errors.New("Session has been revoked")
+	}
+
+	user, err := identityUser(c, decoded.UID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	touchIdentitySession(c, user.ID)
+
+	// Logout revokes the account the cookie was created for
+	c.Locals("session_cookie", decoded)
+	return user, user.FirebaseUID, nil
+}
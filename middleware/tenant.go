@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"Auth/database"
+	"Auth/models"
+	"Auth/tenancy"
+	"Auth/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TenantPathPrefix selects a tenant by slug, e.g. /t/nord/api/job/getjob
+const TenantPathPrefix = "/t/"
+
+// tenantCacheTTL bounds how long a tenant lookup is reused, so resolving a
+// tenant costs no query on most requests
+const tenantCacheTTL = 30 * time.Second
+
+// tenantCacheSize caps the cache, the Host header is chosen by the client
+const tenantCacheSize = 1024
+
+type cachedTenant struct {
+	tenant    *models.Tenant // nil when no tenant matched
+	expiresAt time.Time
+}
+
+var (
+	tenantCacheMu sync.Mutex
+	tenantCache   = map[string]cachedTenant{} // "slug:<slug>" | "domain:<host>" | "id:<id>"
+)
+
+// lookupTenant finds a tenant by column, through the cache
+func lookupTenant(column string, value interface{}) (*models.Tenant, error) {
+	key := column + ":" + fmt.Sprint(value)
+	tenantCacheMu.Lock()
+	cached, ok := tenantCache[key]
+	tenantCacheMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.tenant, nil
+	}
+
+	var tenant models.Tenant
+	err := database.DB.WithContext(tenancy.System(context.Background())).
+		Where(column+" = ?", value).First(&tenant).Error
+	var found *models.Tenant
+	switch {
+	case err == nil:
+		found = &tenant
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	tenantCacheMu.Lock()
+	if len(tenantCache) >= tenantCacheSize {
+		clear(tenantCache)
+	}
+	tenantCache[key] = cachedTenant{tenant: found, expiresAt: time.Now().Add(tenantCacheTTL)}
+	tenantCacheMu.Unlock()
+	return found, nil
+}
+
+// requestHost is the host name of the request without port
+func requestHost(c *fiber.Ctx) string {
+	host := c.Hostname()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// tokenTenant reads the tid claim of our own bearer token, 0 when there is none
+func tokenTenant(c *fiber.Ctx) uint {
+	token, err := bearerToken(c)
+	if err != nil {
+		return 0
+	}
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		return 0
+	}
+	return claims.TenantID
+}
+
+// ResolveTenant picks the job board of the request and limits its database
+// queries to it (see tenancy). In order:
+//
+//   - path prefix /t/<slug>/..., stripped before routing
+//   - Host header matching a tenant's domain
+//   - tid claim of the bearer access token
+//   - the default tenant (TENANT_DEFAULT)
+//
+// A token issued for one board is refused on another. API keys, OAuth
+// tokens and Firebase ID tokens carry no tenant and must be used on their
+// board's host or path; on any other board their user does not exist.
+func ResolveTenant() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var tenant *models.Tenant
+		var err error
+
+		if rest, ok := strings.CutPrefix(c.Path(), TenantPathPrefix); ok {
+			slug, path, _ := strings.Cut(rest, "/")
+			if tenant, err = lookupTenant("slug", strings.ToLower(slug)); err == nil && tenant != nil {
+				c.Path("/" + path)
+			}
+			if err == nil && tenant == nil {
+				return c.Status(404).JSON(fiber.Map{
+					"success": false,
+					"message": "Unknown tenant",
+				})
+			}
+		}
+		if err == nil && tenant == nil {
+			tenant, err = lookupTenant("domain", requestHost(c))
+		}
+
+		claimed := tokenTenant(c)
+		if err == nil && tenant == nil && claimed != 0 {
+			tenant, err = lookupTenant("id", claimed)
+		}
+		if err == nil && tenant == nil {
+			tenant, err = lookupTenant("slug", tenancy.DefaultSlug())
+		}
+
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		if tenant == nil || tenant.Status != models.StatusActive {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Unknown tenant",
+			})
+		}
+		if claimed != 0 && claimed != tenant.ID {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"message": "Token was issued for another tenant",
+			})
+		}
+
+		c.SetUserContext(tenancy.WithTenant(c.UserContext(), tenant.ID))
+		c.Locals("tenant_id", tenant.ID)
+		c.Locals("tenant", tenant.Slug)
+		return c.Next()
+	}
+}
+
+// RequireOperatorTenant limits a route to the default tenant, whose admins
+// run the deployment. It is for changes to rows all tenants share, such as
+// roles and their permissions, which no single job board may rewrite.
+func RequireOperatorTenant() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if slug, _ := c.Locals("tenant").(string); slug != tenancy.DefaultSlug() {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"message": "Only available to the operator tenant",
+			})
+		}
+		return c.Next()
+	}
+}
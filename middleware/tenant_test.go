@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireOperatorTenant(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultEnv string
+		tenant     interface{}
+		wantStatus int
+	}{
+		{"default tenant", "", "default", 200},
+		{"configured operator tenant", "acme", "acme", 200},
+		{"another board", "", "nord", 403},
+		{"default slug of another setup", "acme", "default", 403},
+		{"no tenant resolved", "", nil, 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TENANT_DEFAULT", tt.defaultEnv)
+			locals := map[string]interface{}{}
+			if tt.tenant != nil {
+				locals["tenant"] = tt.tenant
+			}
+
+			status, _ := serve(t, fiber.MethodPost, nil, withLocals(locals), RequireOperatorTenant(), ok)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
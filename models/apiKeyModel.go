@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey lets a machine (importer, partner integration) call the API. It
+// acts as the user who owns it, but only within its Scopes (never as an
+// admin); company keys are additionally bound to CompanyID. Only a hash of
+// the key is stored.
+type APIKey struct {
+	gorm.Model
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex;not null"` // ak_xxxxxxxx, shown in lists
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	CompanyID  *uint      `json:"company_id" gorm:"index"`
+	Scopes     string     `json:"scopes"` // space separated, e.g. "jobs:read jobs:write"
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	User       User       `json:"-"`
+	Company    *Company   `json:"-"`
+}
+
+// ScopeList splits Scopes
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
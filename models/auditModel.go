@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditAppendOnly is returned when code tries to change recorded events
+var ErrAuditAppendOnly = errors.New("audit events are append-only")
+
+// Audit event outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records a security relevant action (see package audit). Events
+// are append-only: the hooks below refuse changes through the ORM and
+// database triggers (see database.AutoMigrate) refuse all others.
+type AuditEvent struct {
+	ID         uint                   `json:"id" gorm:"primarykey"`
+	TenantID   uint                   `json:"-" gorm:"index"` // see tenantModel.go
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+	ActorID    *uint                  `json:"actor_id" gorm:"index"` // who did it; nil when unknown (e.g. failed login)
+	Action     string                 `json:"action" gorm:"not null;index"`
+	TargetType string                 `json:"target_type" gorm:"index:idx_audit_events_target"`
+	TargetID   string                 `json:"target_id" gorm:"index:idx_audit_events_target"`
+	Outcome    string                 `json:"outcome" gorm:"not null;index"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	RequestID  string                 `json:"request_id"`
+	Metadata   map[string]interface{} `json:"metadata" gorm:"serializer:json;type:jsonb"`
+}
+
+// BeforeUpdate refuses to change a recorded event
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete refuses to remove a recorded event
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation states as reported by CompanyInvitation.Status
+const (
+	InvitationPending  = "pending"
+	InvitationExpired  = "expired"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+)
+
+// CompanyInvitation asks someone to join a company's team. The emailed link
+// is a signed action token carrying a nonce; only the nonce hash is stored,
+// so resending an invitation invalidates the previous link.
+type CompanyInvitation struct {
+	gorm.Model
+	CompanyID    uint       `json:"company_id" gorm:"not null;index"`
+	Email        string     `json:"email" gorm:"not null;index"` // lowercased
+	Role         string     `json:"role" gorm:"not null"`
+	TokenHash    string     `json:"-" gorm:"not null"`
+	InvitedByID  uint       `json:"invited_by_id" gorm:"not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	AcceptedByID *uint      `json:"accepted_by_id"`
+	RevokedAt    *time.Time `json:"revoked_at"` // revoked by an owner or superseded by a newer invitation
+	Company      Company    `json:"-"`
+	InvitedBy    User       `json:"-"`
+}
+
+// Status reports where the invitation stands at now
+func (i CompanyInvitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case now.After(i.ExpiresAt):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
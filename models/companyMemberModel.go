@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Company member roles, from most to least privileged
+const (
+	MemberOwner     = "owner"     // company profile, team and jobs
+	MemberRecruiter = "recruiter" // jobs
+	MemberViewer    = "viewer"    // read only
+)
+
+// CompanyMember links a user to a company they work for. Removing a member
+// deletes the row.
+type CompanyMember struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;uniqueIndex:idx_company_member"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_company_member;index"`
+	Role      string    `json:"role" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Company   Company   `json:"-"`
+	User      User      `json:"-"`
+}
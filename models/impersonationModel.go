@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Impersonation is an admin acting as a user through a short-lived access
+// token (utils.GenerateImpersonationJWT); TokenID is the token's jti
+type Impersonation struct {
+	gorm.Model
+	ActorID   uint       `json:"actor_id" gorm:"not null;index"` // the admin
+	UserID    uint       `json:"user_id" gorm:"not null;index"`  // the impersonated user
+	TokenID   string     `json:"-" gorm:"uniqueIndex;not null"`
+	Reason    string     `json:"reason" gorm:"not null"` // e.g. the support ticket
+	IP        string     `json:"ip"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	EndedAt   *time.Time `json:"ended_at"` // stopped before expiry
+	Actor     User       `json:"-"`
+	User      User       `json:"-"`
+}
+
+// ImpersonationRequest records one request made with an impersonation token
+type ImpersonationRequest struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	ImpersonationID uint      `json:"impersonation_id" gorm:"not null;index"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	Status          int       `json:"status"`
+	IP              string    `json:"ip"`
+	RequestID       string    `json:"request_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
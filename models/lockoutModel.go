@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginThrottle counts recent failed attempts for one subject: a client IP
+// ("ip:1.2.3.4"), a known account ("user:42") or an unknown email
+// ("email:a@b.c"). Rows are deleted, not soft deleted, when the counter resets.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey"`
+	ThrottleKey   string     `gorm:"uniqueIndex;not null"`
+	UserID        *uint      `gorm:"index"`
+	Failures      int        `gorm:"not null;default:0"`
+	LastFailureAt time.Time  `gorm:"not null"`
+	BlockedUntil  *time.Time // exponential backoff
+	LockedUntil   *time.Time // account lockout, lifted early by an admin unlock
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// LockoutEvent records why a subject was slowed down or locked, so support
+// can explain failed logins. Append only.
+type LockoutEvent struct {
+	gorm.Model
+	UserID      *uint      `json:"user_id" gorm:"index"`
+	ThrottleKey string     `json:"subject" gorm:"index;not null"`
+	IP          string     `json:"ip"`
+	Event       string     `json:"event"` // backoff, locked, ip_blocked, unlocked
+	Failures    int        `json:"failures"`
+	Until       *time.Time `json:"until"`
+	ActorID     *uint      `json:"actor_id"` // admin who unlocked
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MagicLink is a single-use passwordless sign-in link. The emailed token
+// carries the nonce; only hashes are stored. The link only works together
+// with the device secret handed to the client that asked for it.
+type MagicLink struct {
+	gorm.Model
+	TenantID   uint       `json:"-" gorm:"index"` // see tenantModel.go
+	Email      string     `gorm:"not null;index"` // lowercased
+	TokenHash  string     `gorm:"uniqueIndex;not null"`
+	DeviceHash string     `gorm:"not null"`
+	IP         string     // of the request
+	ExpiresAt  time.Time  `gorm:"not null"`
+	UsedAt     *time.Time // set when consumed or superseded by a newer request
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TOTPFactor is an authenticator app enrolled by a user. It only guards
+// logins once ConfirmedAt is set by a first valid code.
+type TOTPFactor struct {
+	gorm.Model
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex;not null"`
+	Secret       string     `json:"-" gorm:"not null"` // base32
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `json:"-"` // last accepted time step, a code cannot be replayed
+}
+
+// RecoveryCode is a one-time fallback code; only its hash is stored
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"not null;index"`
+	UsedAt   *time.Time
+}
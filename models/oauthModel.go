@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OAuthClient is a third-party application allowed to obtain tokens for our
+// API. Public clients (mobile, single page apps) have no secret and must use
+// PKCE; confidential clients authenticate with ClientID and secret. Only a
+// hash of the secret is stored.
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `json:"client_id" gorm:"uniqueIndex;not null"`
+	SecretHash   string `json:"-"` // empty for public clients
+	Name         string `json:"name" gorm:"not null"`
+	RedirectURIs string `json:"redirect_uris"` // space separated, matched exactly
+	Scopes       string `json:"scopes"`        // space separated, the most the client may request
+	GrantTypes   string `json:"grant_types"`   // space separated, e.g. "authorization_code refresh_token"
+	Public       bool   `json:"public"`
+	OwnerID      uint   `json:"owner_id" gorm:"not null;index"` // user who registered the client
+	Owner        User   `json:"-"`
+}
+
+// RedirectURIList splits RedirectURIs
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// ScopeList splits Scopes
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// GrantTypeList splits GrantTypes
+func (c *OAuthClient) GrantTypeList() []string {
+	return strings.Fields(c.GrantTypes)
+}
+
+// AllowsRedirectURI reports whether uri is registered for the client
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsField(c.RedirectURIs, uri)
+}
+
+// AllowsScope reports whether the client may request scope
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	return containsField(c.Scopes, scope)
+}
+
+// AllowsGrantType reports whether the client may use grantType
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	return containsField(c.GrantTypes, grantType)
+}
+
+// containsField reports whether the space separated list contains value
+func containsField(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorizationCode is issued after the user consented and exchanged
+// once at the token endpoint. Only its hash is stored.
+type OAuthAuthorizationCode struct {
+	gorm.Model
+	CodeHash            string `gorm:"uniqueIndex;not null"`
+	ClientID            uint   `gorm:"not null;index"`
+	UserID              uint   `gorm:"not null;index"`
+	RedirectURI         string `gorm:"not null"`
+	Scope               string // granted scopes, space separated
+	CodeChallenge       string // PKCE, S256 only
+	CodeChallengeMethod string
+	Nonce               string     // OpenID Connect, echoed in the ID token
+	ExpiresAt           time.Time  `gorm:"not null"`
+	UsedAt              *time.Time // presenting a used code revokes the tokens issued for it
+	Client              OAuthClient
+	User                User
+}
+
+// OAuthConsent remembers the scopes a user granted a client, so the consent
+// screen can be skipped next time. Withdrawing it deletes the row.
+type OAuthConsent struct {
+	ID        uint        `json:"id" gorm:"primarykey"`
+	UserID    uint        `json:"user_id" gorm:"not null;uniqueIndex:idx_oauth_consent_user_client"`
+	ClientID  uint        `json:"client_id" gorm:"not null;uniqueIndex:idx_oauth_consent_user_client"`
+	Scope     string      `json:"scope"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Client    OAuthClient `json:"-"`
+	User      User        `json:"-"`
+}
+
+// OAuthAccessToken records the jti of an access token issued for a user, so
+// withdrawing the consent can denylist the tokens still valid. Rows past
+// ExpiresAt can be purged.
+type OAuthAccessToken struct {
+	ID              uint      `gorm:"primarykey"`
+	JTI             string    `gorm:"uniqueIndex;not null"`
+	ClientID        uint      `gorm:"not null;index:idx_oauth_access_token_client_user"`
+	UserID          uint      `gorm:"not null;index:idx_oauth_access_token_client_user"`
+	AuthorizationID *uint     `gorm:"index"` // code the token was issued from, directly or by refresh
+	ExpiresAt       time.Time `gorm:"not null;index"`
+	CreatedAt       time.Time
+}
+
+// OAuthRefreshToken is a single-use opaque refresh token of an OAuth client.
+// Tokens rotated from the same authorization share a FamilyID, so reuse of
+// an old token revokes the whole family.
+type OAuthRefreshToken struct {
+	gorm.Model
+	TokenHash       string `gorm:"uniqueIndex;not null"`
+	FamilyID        string `gorm:"not null;index"`
+	ClientID        uint   `gorm:"not null;index"`
+	UserID          uint   `gorm:"not null;index"`
+	AuthorizationID *uint  `gorm:"index"` // code the family started from
+	Scope           string
+	ExpiresAt       time.Time `gorm:"not null"`
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+	Client          OAuthClient
+	User            User
+}
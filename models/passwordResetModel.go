@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordReset is a single-use reset link; only the token hash is stored
+type PasswordReset struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // set when consumed or superseded by a newer request
+	User      User
+}
@@ -0,0 +1,56 @@
+package models
+
+import "gorm.io/gorm"
+
+// Permissions checked by middleware.RequirePermission, named resource:action
+const (
+	PermJobCreate     = "job:create"
+	PermJobUpdate     = "job:update"
+	PermJobDelete     = "job:delete"
+	PermCompanyCreate = "company:create"
+	PermCompanyUpdate = "company:update"
+	PermCompanyDelete = "company:delete"
+	PermJobTypeCreate = "jobtype:create"
+	PermJobTypeUpdate = "jobtype:update"
+	PermJobTypeDelete = "jobtype:delete"
+	PermUserAdmin     = "user:admin" // roles, lockouts and other account administration
+)
+
+// Permission is a single capability; roles bundle permissions
+type Permission struct {
+	gorm.Model
+	Name        string `json:"name" gorm:"uniqueIndex;not null"`
+	Description string `json:"description"`
+	Roles       []Role `json:"-" gorm:"many2many:role_permissions;"`
+}
+
+// DefaultPermissions lists every built-in permission with its description
+var DefaultPermissions = []Permission{
+	{Name: PermJobCreate, Description: "Post jobs"},
+	{Name: PermJobUpdate, Description: "Edit jobs"},
+	{Name: PermJobDelete, Description: "Delete jobs"},
+	{Name: PermCompanyCreate, Description: "Create companies"},
+	{Name: PermCompanyUpdate, Description: "Edit companies"},
+	{Name: PermCompanyDelete, Description: "Delete companies"},
+	{Name: PermJobTypeCreate, Description: "Create job types"},
+	{Name: PermJobTypeUpdate, Description: "Edit job types"},
+	{Name: PermJobTypeDelete, Description: "Delete job types"},
+	{Name: PermUserAdmin, Description: "Administer accounts, roles and permissions"},
+}
+
+// DefaultRolePermissions is what the built-in roles get when a permission is
+// first seeded; later changes made by admins are kept. Company and job
+// permissions only open the endpoint: which companies a user may touch is
+// decided by their membership role.
+var DefaultRolePermissions = map[string][]string{
+	"user": {
+		PermJobCreate, PermJobUpdate, PermJobDelete,
+		PermCompanyCreate, PermCompanyUpdate, PermCompanyDelete,
+	},
+	"admin": {
+		PermJobCreate, PermJobUpdate, PermJobDelete,
+		PermCompanyCreate, PermCompanyUpdate, PermCompanyDelete,
+		PermJobTypeCreate, PermJobTypeUpdate, PermJobTypeDelete,
+		PermUserAdmin,
+	},
+}
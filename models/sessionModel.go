@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a refresh token family started by a successful login.
+// Every refresh rotates the token inside the same family; revoking the
+// session invalidates all of its refresh tokens at once.
+type Session struct {
+	gorm.Model
+	UserID        uint           `json:"user_id" gorm:"not null;index"`
+	Provider      string         `json:"provider"` // password, google, etc.
+	UserAgent     string         `json:"user_agent"`
+	Device        string         `json:"device"`       // e.g. "Chrome on macOS", derived from the user agent
+	IP            string         `json:"ip"`           // of the latest activity
+	LastSeenAt    time.Time      `json:"last_seen_at"` // refreshed at most once a minute
+	ExpiresAt     time.Time      `json:"expires_at"`
+	RevokedAt     *time.Time     `json:"revoked_at"`
+	RevokedReason string         `json:"revoked_reason"` // logout, refresh_token_reuse, ...
+	User          User           `json:"-"`
+	RefreshTokens []RefreshToken `json:"-"`
+}
+
+// RefreshToken is a single-use opaque token; only its hash is stored.
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // set when rotated; presenting it again means reuse
+	Session   Session
+}
+
+// RevokedToken denylists a single access token by its jti until it would
+// have expired anyway; rows past ExpiresAt can be purged.
+type RevokedToken struct {
+	gorm.Model
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tenant is one job board served by this deployment. User, Company, Job and
+// JobType rows carry a TenantID and are scoped to the tenant of the request
+// by the tenancy package. The database keeps users unique per tenant; the
+// sign-up paths also keep them unique across tenants while one identity
+// provider project backs every board, so an account belongs to one board.
+type Tenant struct {
+	gorm.Model
+	Slug   string  `json:"slug" gorm:"uniqueIndex;not null"` // path prefix /t/<slug>/
+	Name   string  `json:"name" gorm:"not null"`
+	Domain *string `json:"domain" gorm:"uniqueIndex"` // host name the board is served on
+	Status int     `json:"status" gorm:"not null"`
+}
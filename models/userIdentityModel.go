@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UserIdentity is a sign-in method linked to a user: the provider (password,
+// google, ...) and the user's ID there. FirebaseUID is the identity-provider
+// account the method belongs to; it differs from User.FirebaseUID when a
+// separate account was linked. Unlinked identities are deleted for good, so
+// the method can be linked again.
+type UserIdentity struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	Provider    string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_identities_provider_uid"`
+	ProviderUID string    `json:"provider_uid" gorm:"not null;uniqueIndex:idx_user_identities_provider_uid"`
+	FirebaseUID string    `json:"-" gorm:"not null;index"`
+	Email       string    `json:"email"`
+	LinkedAt    time.Time `json:"linked_at" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	User        User      `json:"-"`
+}
@@ -14,14 +14,16 @@ const (
 
 type User struct {
 	gorm.Model
-	ID           uint   `gorm:"primaryKey"`
-	FirebaseUID  string `gorm:"uniqueIndex;not null"`
-	Username     string `json:"username" gorm:"uniqueIndex;not null"`
-	Email        string `json:"email" gorm:"uniqueIndex"`
-	Password     string `json:"password"`
-	Provider     string `json:"provider"` // password, google, etc.
-	User_Details User_Details
-	Roles        []Role `gorm:"many2many:user_roles;"` // many to many
+	ID              uint       `gorm:"primaryKey"`
+	TenantID        uint       `json:"-" gorm:"uniqueIndex:idx_users_tenant_firebase_uid;uniqueIndex:idx_users_tenant_username;uniqueIndex:idx_users_tenant_email"` // see tenantModel.go
+	FirebaseUID     string     `gorm:"not null;uniqueIndex:idx_users_tenant_firebase_uid"`
+	Username        string     `json:"username" gorm:"not null;uniqueIndex:idx_users_tenant_username"`
+	Email           string     `json:"email" gorm:"uniqueIndex:idx_users_tenant_email"`
+	Password        string     `json:"-"`                 // bcrypt/argon2id hash, empty for social-only accounts
+	Provider        string     `json:"provider"`          // password, google, etc.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // nil until the address is confirmed
+	User_Details    User_Details
+	Roles           []Role `gorm:"many2many:user_roles;"` // many to many
 }
 type User_Details struct {
 	gorm.Model
@@ -34,20 +36,23 @@ type User_Details struct {
 }
 type Role struct {
 	gorm.Model
-	Name  string `gorm:"unique;not null"`
-	Users []User `gorm:"many2many:user_roles;"` //  Many-to-Many
+	Name        string       `gorm:"unique;not null"`
+	Users       []User       `gorm:"many2many:user_roles;"`       //  Many-to-Many
+	Permissions []Permission `gorm:"many2many:role_permissions;"` // see permissionModel.go
 }
 type JobType struct {
 	gorm.Model
-	Name   string `json:"name" gorm:"unique;not null"`
-	Status int    `json:"status"`
-	Jobs   []Job  `gorm:"foreignKey:JobTypeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	TenantID uint   `json:"-" gorm:"uniqueIndex:idx_job_types_tenant_name"`
+	Name     string `json:"name" gorm:"not null;uniqueIndex:idx_job_types_tenant_name"`
+	Status   int    `json:"status"`
+	Jobs     []Job  `gorm:"foreignKey:JobTypeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 }
 
 type Company struct {
 	gorm.Model
+	TenantID    uint   `json:"-" gorm:"uniqueIndex:idx_companies_tenant_email"`
 	Name        string `json:"name" gorm:"not null"`
-	Email       string `json:"email" gorm:"unique;not null"`
+	Email       string `json:"email" gorm:"not null;uniqueIndex:idx_companies_tenant_email"`
 	Address     string `json:"address" gorm:"not null"`
 	Description string `json:"description"`
 	Logo        string `json:"logo"`
@@ -57,6 +62,7 @@ type Company struct {
 
 type Job struct {
 	gorm.Model
+	TenantID    uint      `json:"-" gorm:"index"`
 	Name        string    `json:"name" gorm:"not null"`
 	Description string    `json:"description"`
 	SalaryStart int64     `json:"salary_start"`
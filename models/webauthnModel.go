@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential is a passkey (platform authenticator or security key)
+// registered by a user. Only the public key is stored.
+type WebAuthnCredential struct {
+	gorm.Model
+	UserID          uint       `json:"user_id" gorm:"not null;index"`
+	Name            string     `json:"name"` // label chosen by the user, e.g. "MacBook"
+	CredentialID    []byte     `json:"-" gorm:"uniqueIndex;not null"`
+	PublicKey       []byte     `json:"-" gorm:"not null"` // COSE encoded
+	AttestationType string     `json:"attestation_type"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	Transports      string     `json:"transports"` // comma separated: internal,hybrid,usb,...
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"` // synced passkey
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	User            User       `json:"-"`
+}
+
+// WebAuthnSession holds the challenge of a ceremony between its begin and
+// finish calls. It is single use; UserID is 0 for a passkey login, where the
+// user is only known once the authenticator answers.
+type WebAuthnSession struct {
+	gorm.Model
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	UserID    uint      `gorm:"index"`
+	Ceremony  string    `gorm:"not null"`           // registration or login
+	Data      string    `gorm:"type:text;not null"` // JSON webauthn.SessionData
+	ExpiresAt time.Time `gorm:"not null"`
+}
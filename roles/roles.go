@@ -0,0 +1,223 @@
+// Package roles assigns roles to users and keeps the identity provider's
+// custom claims in line with the user_roles table.
+package roles
+
+import (
+	"Auth/database"
+	"Auth/firebase"
+	"Auth/identity"
+	"Auth/models"
+	"Auth/tenancy"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Built-in roles created by database.SeedRoles
+const (
+	User  = "user"
+	Admin = "admin"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrInvalidRoleName   = errors.New("role names are 2-50 lowercase letters, digits, '_' or '-'")
+	ErrLastAdmin         = errors.New("cannot revoke the admin role from the last admin")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrAdminLockout      = errors.New("the admin role must keep the user:admin permission")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+// ValidName reports whether name can be used for a custom role
+func ValidName(name string) bool {
+	return roleNamePattern.MatchString(name)
+}
+
+// Primary picks the role claim of access tokens: admin wins over custom
+// roles, which win over user. Middleware always reads roles fresh from the
+// database, the claim is informational for clients.
+func Primary(roles []models.Role) string {
+	primary := User
+	for _, role := range roles {
+		switch {
+		case role.Name == Admin:
+			return Admin
+		case role.Name != User && primary == User:
+			primary = role.Name
+		}
+	}
+	return primary
+}
+
+// Create adds a custom role
+func Create(name string) (*models.Role, error) {
+	if !ValidName(name) {
+		return nil, ErrInvalidRoleName
+	}
+
+	var existing int64
+	if err := database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrRoleExists
+	}
+
+	role := models.Role{Name: name}
+	if err := database.DB.Create(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// loadUser reads a user with roles inside tx
+func loadUser(tx *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := tx.Preload("Roles").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// hasRole reports whether the user has the role
+func hasRole(user *models.User, name string) bool {
+	for _, role := range user.Roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Grant gives the user a role. changed is false when the user already had it.
+// The returned user has its roles loaded.
+func Grant(ctx context.Context, userID uint, roleName string) (user *models.User, changed bool, err error) {
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		if user, err = loadUser(tx, userID); err != nil {
+			return err
+		}
+		if hasRole(user, roleName) {
+			return nil
+		}
+
+		if err := tx.Model(user).Association("Roles").Append(&role); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return user, changed, err
+}
+
+// Revoke takes a role from the user. The last admin keeps the admin role,
+// so the admin API can never lock itself out.
+func Revoke(ctx context.Context, userID uint, roleName string) (user *models.User, changed bool, err error) {
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		// the lock serialises concurrent revocations of the same role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		if user, err = loadUser(tx, userID); err != nil {
+			return err
+		}
+		if !hasRole(user, roleName) {
+			return nil
+		}
+
+		if roleName == Admin {
+			// the join is not rewritten by the tenancy callbacks: count the
+			// admins of this tenant only, and fail closed without one
+			tenantID, ok := tenancy.FromContext(ctx)
+			if !ok {
+				return tenancy.ErrNoTenant
+			}
+			var admins int64
+			if err := tx.Table("user_roles").
+				Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL AND users.tenant_id = ?", tenantID).
+				Where("user_roles.role_id = ?", role.ID).
+				Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		if err := tx.Model(user).Association("Roles").Delete(&role); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return user, changed, err
+}
+
+// SetPermissions replaces the permissions of a role. The admin role always
+// keeps user:admin, otherwise nobody could repair the setup.
+func SetPermissions(roleName string, names []string) (*models.Role, error) {
+	if roleName == Admin && !slices.Contains(names, models.PermUserAdmin) {
+		return nil, ErrAdminLockout
+	}
+
+	var role models.Role
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		permissions := []models.Permission{}
+		if len(names) > 0 {
+			if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			if !slices.ContainsFunc(permissions, func(p models.Permission) bool { return p.Name == name }) {
+				return fmt.Errorf("%w: %s", ErrUnknownPermission, name)
+			}
+		}
+
+		if len(permissions) == 0 {
+			return tx.Model(&role).Association("Permissions").Clear()
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// SyncClaims writes the user's roles to the identity provider's custom
+// claims, so they show up in the next ID token
+func SyncClaims(ctx context.Context, user *models.User) error {
+	return identity.Get().SetCustomUserClaims(ctx, user.FirebaseUID, firebase.GenerateUserClaims(*user))
+}
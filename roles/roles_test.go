@@ -0,0 +1,68 @@
+package roles
+
+import (
+	"Auth/database"
+	"Auth/tenancy"
+	"Auth/test"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestRevokeLastAdmin(t *testing.T) {
+	board := tenancy.WithTenant(context.Background(), 7)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		admins  int64 // admins of the board
+		wantErr error
+	}{
+		{"last admin of the board", board, 1, ErrLastAdmin},
+		{"board has another admin", board, 2, nil},
+		{"no tenant to count in", tenancy.System(context.Background()), 2, tenancy.ErrNoTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake, err := test.OpenFakeDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tenancy.Register(db); err != nil {
+				t.Fatal(err)
+			}
+			previous := database.DB
+			database.DB = db
+			t.Cleanup(func() { database.DB = previous })
+
+			fake.On(`FROM "roles" WHERE name`).Rows(
+				[]string{"id", "name"},
+				[]driver.Value{int64(2), Admin})
+			fake.On(`FROM "users"`).Rows(
+				[]string{"id", "tenant_id"},
+				[]driver.Value{int64(42), int64(7)})
+			fake.On(`FROM "user_roles"`, "count(").Rows(
+				[]string{"count"},
+				[]driver.Value{tt.admins})
+			fake.On(`FROM "user_roles"`).Rows(
+				[]string{"user_id", "role_id"},
+				[]driver.Value{int64(42), int64(2)})
+			fake.On(`FROM "roles" WHERE "roles"."id"`).Rows(
+				[]string{"id", "name"},
+				[]driver.Value{int64(2), Admin})
+
+			_, changed, err := Revoke(tt.ctx, 42, Admin)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if changed != (tt.wantErr == nil) {
+				t.Errorf("changed = %v, want %v", changed, tt.wantErr == nil)
+			}
+			if tt.ctx == board && !fake.Ran("count(", "users.tenant_id = $") {
+				t.Error("admins were counted across tenants")
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"Auth/controllers"
+	"Auth/identity"
 	"Auth/middleware"
+	"Auth/models"
 	"Auth/test"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,15 +14,97 @@ func AuthRoute(router fiber.Router) {
 	router.Post("/register", controllers.Register)
 	router.Post("/test", test.TestClaims)
 	router.Post("/register", controllers.Register)
-	router.Post("/sociallogin", controllers.LoginSocialFirebase)
-	router.Post("/firebase-login", controllers.LoginWithFirebase)
-	router.Post("/set-new-passwordemail", controllers.ForgotPasswordByEmail)
-
-	// Protected routes (require Firebase auth)
-	router.Put("/update-user", middleware.FirebaseAuth(), controllers.UpdateProfile)
-	router.Get("/GetProfile", middleware.FirebaseAuth(), controllers.GetProfile)
-	router.Post("/logout", middleware.FirebaseAuth(), controllers.Logout)
-	router.Delete("/deletecurrent", middleware.FirebaseAuth(), controllers.DeleteCurrentUser)
+
+	// Credential endpoints are throttled per IP and per account
+	guard := middleware.LoginGuard()
+	router.Post("/sociallogin", guard, controllers.LoginSocialFirebase)
+	router.Post("/firebase-login", guard, controllers.LoginWithFirebase)
+	router.Post("/login", guard, controllers.LoginWithPassword)
+	router.Post("/set-new-passwordemail", middleware.RequestGuard("password_reset"), controllers.ForgotPasswordByEmail)
+	router.Post("/reset-password", controllers.ResetPassword)
+	router.Post("/refresh", controllers.RefreshToken)
+	// Browser clients: exchange a fresh ID token for an HttpOnly session cookie
+	router.Post("/session", guard, controllers.CreateSession)
+	router.Get("/verify-email", controllers.VerifyEmail)
+	router.Post("/verify-email", controllers.VerifyEmail)
+
+	// Passwordless sign-in by emailed link, bound to the requesting device
+	router.Post("/magic-link", middleware.RequestGuard("magic_link"), controllers.RequestMagicLink)
+	router.Get("/magic-link/verify", guard, controllers.VerifyMagicLink)
+	router.Post("/magic-link/verify", guard, controllers.VerifyMagicLink)
+
+	// Offline sign-in, replaces the Firebase client SDK when IDENTITY_PROVIDER=local
+	if identity.Get().Name() == "local" {
+		router.Post("/local/signin", guard, controllers.LocalSignIn)
+	}
+
+	// Protected routes (accept our own JWT, a Firebase ID token or session cookie)
+	authn := middleware.Authenticate(middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	verified := middleware.RequireVerifiedEmail()
+	// destructive account changes are refused with an impersonation token
+	noImp := middleware.DenyImpersonation()
+	router.Put("/update-user", authn, noImp, verified, controllers.UpdateProfile)
+	router.Get("/GetProfile", authn, controllers.GetProfile)
+	router.Post("/logout", authn, noImp, controllers.Logout)
+	router.Delete("/session", authn, noImp, controllers.Logout)
+	router.Delete("/deletecurrent", authn, noImp, verified, controllers.DeleteCurrentUser)
+	router.Post("/verify-email/resend", authn, controllers.ResendVerificationEmail)
+
+	// Signed-in devices
+	router.Get("/sessions", authn, controllers.ListSessions)
+	router.Post("/sessions/revoke-others", authn, noImp, controllers.RevokeOtherSessions)
+	router.Delete("/sessions/:id", authn, noImp, controllers.RevokeSession)
+
+	// API keys for machine-to-machine access (managed by people, not by keys)
+	router.Post("/api-keys", authn, noImp, verified, controllers.CreateAPIKey)
+	router.Get("/api-keys", authn, controllers.ListAPIKeys)
+	router.Post("/api-keys/:id/rotate", authn, noImp, controllers.RotateAPIKey)
+	router.Delete("/api-keys/:id", authn, noImp, controllers.RevokeAPIKey)
+
+	// Two-factor authentication (TOTP)
+	router.Post("/mfa/verify", guard, controllers.VerifyMFA)
+	router.Post("/mfa/totp/enroll", authn, noImp, verified, controllers.EnrollTOTP)
+	router.Post("/mfa/totp/confirm", authn, noImp, controllers.ConfirmTOTP)
+	router.Post("/mfa/totp/disable", authn, noImp, controllers.DisableTOTP)
+
+	// Passkeys (WebAuthn)
+	router.Post("/webauthn/login/begin", controllers.WebAuthnLoginBegin)
+	router.Post("/webauthn/login/finish", guard, controllers.WebAuthnLoginFinish)
+	router.Post("/webauthn/register/begin", authn, noImp, verified, controllers.WebAuthnRegisterBegin)
+	router.Post("/webauthn/register/finish", authn, noImp, controllers.WebAuthnRegisterFinish)
+	router.Get("/webauthn/credentials", authn, controllers.ListWebAuthnCredentials)
+	router.Delete("/webauthn/credentials/:id", authn, noImp, controllers.DeleteWebAuthnCredential)
+
+	// Linked sign-in methods (password, google, ...)
+	router.Get("/identities", authn, controllers.ListIdentities)
+	router.Post("/identities", authn, noImp, verified, controllers.LinkIdentity)
+	router.Delete("/identities/:id", authn, noImp, controllers.UnlinkIdentity)
+
+	// Login lockouts (admin)
+	admin := middleware.RequirePermission(models.PermUserAdmin)
+	router.Get("/admin/lockouts/events", authn, admin, controllers.ListLockoutEvents)
+	router.Post("/admin/lockouts/:userId/unlock", authn, admin, controllers.UnlockAccount)
+
+	// Role management (admin)
+	router.Get("/admin/roles", authn, admin, controllers.ListRoles)
+	// roles are shared by every tenant, only the operator may change them
+	operator := middleware.RequireOperatorTenant()
+	router.Post("/admin/roles", authn, admin, operator, controllers.CreateRole)
+	router.Put("/admin/roles/:role/permissions", authn, admin, operator, controllers.SetRolePermissions)
+	router.Get("/admin/permissions", authn, admin, controllers.ListPermissions)
+	router.Get("/admin/users/:userId/roles", authn, admin, controllers.ListUserRoles)
+	router.Post("/admin/users/:userId/roles", authn, admin, controllers.GrantRole)
+	router.Delete("/admin/users/:userId/roles/:role", authn, admin, controllers.RevokeRole)
+
+	// Impersonation for support (admin); every impersonated request is recorded
+	router.Post("/admin/users/:userId/impersonate", authn, noImp, admin, controllers.StartImpersonation)
+	router.Get("/admin/impersonations", authn, admin, controllers.ListImpersonations)
+	router.Get("/admin/impersonations/:id/requests", authn, admin, controllers.ListImpersonatedRequests)
+	router.Post("/impersonation/stop", authn, controllers.StopImpersonation)
+
+	// Audit trail (admin); the export is NDJSON for SIEM collectors
+	router.Get("/admin/audit-events", authn, admin, controllers.ListAuditEvents)
+	router.Get("/admin/audit-events/export", authn, admin, controllers.ExportAuditEvents)
 
 	// User details management routes
 	router.Group("/user")
@@ -2,15 +2,35 @@ package companies
 
 import (
 	company "Auth/controllers/companies"
+	"Auth/middleware"
+	"Auth/models"
+	"Auth/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func CompanyRoutes(router fiber.Router) {
 	// TypeJobes routes
-	router.Post("/createcom", company.CreateCompany)
+	// reads are public; writes need a permission and, for machines, a scope
+	authn := middleware.Authenticate(middleware.AuthAPIKey, middleware.AuthOAuth, middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	write := middleware.RequireScope(utils.ScopeCompaniesWrite)
+	router.Post("/createcom", authn, write, middleware.RequirePermission(models.PermCompanyCreate), company.CreateCompany)
 	router.Get("/getcom", company.GetAllCompany)
 	router.Get("/getbyid/:id", company.GetCompanyByID)
-	router.Put("/update/:id", company.UpdateCompany)
-	router.Delete("/delete/:id", company.DeleteCompany)
+	router.Put("/update/:id", authn, write, middleware.RequirePermission(models.PermCompanyUpdate), company.UpdateCompany)
+	router.Delete("/delete/:id", authn, middleware.DenyImpersonation(), write, middleware.RequirePermission(models.PermCompanyDelete), company.DeleteCompany)
+
+	// Company team, checked against the membership of the signed-in user
+	members := middleware.Authenticate(middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	noImp := middleware.DenyImpersonation()
+	router.Get("/:id/members", members, company.ListMembers)
+	router.Put("/:id/members/:userId", members, noImp, company.UpdateMember)
+	router.Delete("/:id/members/:userId", members, noImp, company.RemoveMember)
+
+	// Team invitations; the invitee accepts with a verified address
+	router.Post("/invitations/accept", members, noImp, middleware.RequireVerifiedEmail(), company.AcceptInvitation)
+	router.Post("/:id/invitations", members, noImp, middleware.RequireVerifiedEmail(), company.CreateInvitation)
+	router.Get("/:id/invitations", members, company.ListInvitations)
+	router.Post("/:id/invitations/:invitationId/resend", members, noImp, company.ResendInvitation)
+	router.Delete("/:id/invitations/:invitationId", members, noImp, company.RevokeInvitation)
 }
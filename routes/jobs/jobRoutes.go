@@ -2,17 +2,23 @@ package jobs
 
 import (
 	jobs "Auth/controllers/jobsController"
+	"Auth/middleware"
+	"Auth/models"
+	"Auth/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func JobRoutes(router fiber.Router) {
 	// TypeJobes routes
-	router.Post("/createjob", jobs.CreateJob)
+	// importers and partners use API keys or OAuth tokens, people their usual tokens
+	authn := middleware.Authenticate(middleware.AuthAPIKey, middleware.AuthOAuth, middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	write := middleware.RequireScope(utils.ScopeJobsWrite)
+	router.Post("/createjob", authn, write, middleware.RequirePermission(models.PermJobCreate), jobs.CreateJob)
 	// router.Get("/getcom", company.GetAllCompany)
 	// router.Get("/getbyid/:id", company.GetCompanyByID)
-	router.Put("/update/:id", jobs.UpdateJob)
-	router.Delete("/delete/:id", jobs.DeleteJob)
+	router.Put("/update/:id", authn, write, middleware.RequirePermission(models.PermJobUpdate), jobs.UpdateJob)
+	router.Delete("/delete/:id", authn, middleware.DenyImpersonation(), write, middleware.RequirePermission(models.PermJobDelete), jobs.DeleteJob)
 	router.Get("/getall", jobs.GetAllJobs)
 	router.Get("/getbyid/:id", jobs.GetJobByID)
 	router.Get("/get", jobs.GetJobByID)
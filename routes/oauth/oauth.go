@@ -0,0 +1,36 @@
+package oauth
+
+import (
+	"Auth/controllers/oauth"
+	"Auth/middleware"
+	"Auth/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func OAuthRoutes(router fiber.Router) {
+	// Called by client applications, authenticated as the client
+	router.Post("/token", oauth.Token)
+	router.Post("/introspect", oauth.Introspect)
+	router.Post("/revoke", oauth.Revoke)
+
+	// OpenID Connect userinfo, for OAuth clients and our own apps
+	userinfo := middleware.Authenticate(middleware.AuthOAuth, middleware.AuthJWT)
+	openid := middleware.RequireScope(utils.ScopeOpenID)
+	router.Get("/userinfo", userinfo, openid, oauth.UserInfo)
+	router.Post("/userinfo", userinfo, openid, oauth.UserInfo)
+
+	// Consent page and app management, done by people with their own tokens
+	authn := middleware.Authenticate(middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	verified := middleware.RequireVerifiedEmail()
+	noImp := middleware.DenyImpersonation()
+	router.Get("/authorize", authn, oauth.Authorize)
+	router.Post("/authorize", authn, noImp, verified, oauth.AuthorizeDecision)
+	router.Get("/consents", authn, oauth.ListConsents)
+	router.Delete("/consents/:clientId", authn, noImp, oauth.RevokeConsent)
+
+	// Client registration
+	router.Post("/clients", authn, noImp, verified, oauth.RegisterClient)
+	router.Get("/clients", authn, oauth.ListClients)
+	router.Delete("/clients/:id", authn, noImp, oauth.DeleteClient)
+}
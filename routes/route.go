@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"Auth/controllers"
+	"Auth/middleware"
 	auth "Auth/routes/auths"
 	"Auth/routes/companies"
 	jobs "Auth/routes/jobs"
+	"Auth/routes/oauth"
 
 	typejob "Auth/routes/typejobs"
 
@@ -11,7 +14,15 @@ import (
 )
 
 func SetupRoutes(app *fiber.App) {
+	// Public verification keys for services that consume our tokens
+	app.Get("/.well-known/jwks.json", controllers.JWKS)
+	app.Get("/.well-known/openid-configuration", controllers.OpenIDConfiguration)
+
+	// Every route below runs for one job board (tenant)
+	app.Use(middleware.ResolveTenant())
+
 	api := app.Group("/api")
+	api.Get("/tenant", controllers.CurrentTenant)
 
 	// Auth routes
 	authGroup := api.Group("/auth")
@@ -27,5 +38,9 @@ func SetupRoutes(app *fiber.App) {
 	// jobs routes
 	job := api.Group("/job")
 	jobs.JobRoutes(job)
+
+	// OAuth 2.0 authorization server for third-party apps
+	oauthGroup := api.Group("/oauth")
+	oauth.OAuthRoutes(oauthGroup)
 	
 }
@@ -2,7 +2,9 @@ package typejob
 
 import (
 	"Auth/controllers"
-	jobtypes "Auth/controllers/jobsController"
+	jobtypes "Auth/controllers/jobtypes"
+	"Auth/middleware"
+	"Auth/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -11,9 +13,12 @@ func TypeJobRoutes(router fiber.Router) {
 	// TypeJobes routes
 	router.Post("/register", controllers.Register)
 	router.Group("/typejobs")
-	router.Post("/createTypejob", jobtypes.CreateJob)
-	router.Get("/getall", jobtypes.GetAllJobs)
-	router.Get("/getbyid/:id", jobtypes.GetJobByID)
-	router.Delete("/delete/:id", jobtypes.DeleteJob)
+	// job types are reference data managed by people
+	authn := middleware.Authenticate(middleware.AuthJWT, middleware.AuthFirebase, middleware.AuthSessionCookie)
+	router.Post("/createTypejob", authn, middleware.RequirePermission(models.PermJobTypeCreate), jobtypes.CreateTypeJob)
+	router.Get("/getall", jobtypes.GetallTypeJob)
+	router.Get("/getbyid/:id", jobtypes.GetTypeJobByID)
+	router.Put("/update/:id", authn, middleware.RequirePermission(models.PermJobTypeUpdate), jobtypes.UpdateTypeJob)
+	router.Delete("/delete/:id", authn, middleware.RequirePermission(models.PermJobTypeDelete), jobtypes.DeleteTypeJob)
 	//router.Get("/search/:name", jobtypes.)
 }
@@ -0,0 +1,163 @@
+// Package tenancy isolates the job boards served by one deployment. The
+// tenant of a request travels in its context, and the GORM callbacks
+// installed by Register scope every query on a model with a TenantID field
+// (User, Company, Job, JobType) to it.
+//
+// Scoping fails closed: a query on a scoped model without a tenant in its
+// context is an error, so a handler that forgets db.WithContext cannot read
+// across boards. Seeding and command line tools opt out with System.
+// Raw SQL and hand-written joins are not rewritten.
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrNoTenant    = errors.New("tenancy: query on a tenant-scoped model without a tenant")
+	ErrCrossTenant = errors.New("tenancy: record belongs to another tenant")
+)
+
+// field is the struct field marking a model as tenant-scoped
+const field = "TenantID"
+
+type tenantKey struct{}
+type systemKey struct{}
+
+// WithTenant returns a context whose queries are limited to the tenant
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext returns the tenant of ctx
+func FromContext(ctx context.Context) (uint, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(uint)
+	return tenantID, ok && tenantID != 0
+}
+
+// System returns a context whose queries see every tenant. Only for
+// seeding, migrations and command line tools, never for requests.
+func System(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+func isSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
+
+// DefaultSlug is the tenant of requests no other rule matches, from
+// TENANT_DEFAULT (default "default")
+func DefaultSlug() string {
+	if slug := os.Getenv("TENANT_DEFAULT"); slug != "" {
+		return slug
+	}
+	return "default"
+}
+
+// Register installs the scoping callbacks on db
+func Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tenancy:create", assignTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenancy:query", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenancy:update", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenancy:delete", scopeTenant); err != nil {
+		return err
+	}
+	return callbacks.Row().Before("gorm:row").Register("tenancy:row", scopeTenant)
+}
+
+// statementTenant returns the scoped field of the statement's model and the
+// tenant to apply; ok is false when the statement is not scoped
+func statementTenant(db *gorm.DB) (tenantField *schema.Field, tenantID uint, ok bool) {
+	if db.Statement.Schema == nil {
+		return nil, 0, false
+	}
+	f := db.Statement.Schema.LookUpField(field)
+	if f == nil || isSystem(db.Statement.Context) {
+		return nil, 0, false
+	}
+
+	tenantID, found := FromContext(db.Statement.Context)
+	if !found {
+		db.AddError(ErrNoTenant)
+		return nil, 0, false
+	}
+	return f, tenantID, true
+}
+
+// scopeTenant limits queries, updates and deletes to the tenant's rows
+func scopeTenant(db *gorm.DB) {
+	f, tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+
+	// a loaded record of another tenant must not be written back either
+	if value := db.Statement.ReflectValue; isModel(db, value) {
+		if current, zero := f.ValueOf(db.Statement.Context, value); !zero && current != tenantID {
+			db.AddError(ErrCrossTenant)
+			return
+		}
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: tenantID},
+	}})
+}
+
+// assignTenant stamps new records with the tenant
+func assignTenant(db *gorm.DB) {
+	f, tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+
+	value := db.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if record := reflect.Indirect(value.Index(i)); isModel(db, record) {
+				setTenant(db, f, record, tenantID)
+			}
+		}
+	case reflect.Struct:
+		if isModel(db, value) {
+			setTenant(db, f, value, tenantID)
+		}
+	}
+}
+
+// isModel reports whether value is a record of the statement's model, as
+// opposed to a row struct the result is scanned into
+func isModel(db *gorm.DB, value reflect.Value) bool {
+	return value.Kind() == reflect.Struct && value.Type() == db.Statement.Schema.ModelType
+}
+
+// setTenant fills in the tenant of a new record and refuses records
+// explicitly built for another tenant
+func setTenant(db *gorm.DB, f *schema.Field, record reflect.Value, tenantID uint) {
+	ctx := db.Statement.Context
+	if current, zero := f.ValueOf(ctx, record); !zero {
+		if current != tenantID {
+			db.AddError(ErrCrossTenant)
+		}
+		return
+	}
+	if err := f.Set(ctx, record, tenantID); err != nil {
+		db.AddError(err)
+	}
+}
@@ -0,0 +1,185 @@
+package tenancy
+
+import (
+	"Auth/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds statements with the Postgres dialect without a server
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=test"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true, // writes would open a connection
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestScopeTenant(t *testing.T) {
+	db := dryRunDB(t)
+	board := WithTenant(context.Background(), 7)
+
+	tests := []struct {
+		name    string
+		run     func(tx *gorm.DB) *gorm.DB
+		want    string // SQL fragment; "" when none is expected
+		notWant string
+		wantErr error
+	}{
+		{
+			name: "query is scoped",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(board).Where("email = ?", "jane@example.com").First(&models.User{})
+			},
+			want: `"users"."tenant_id" = $`,
+		},
+		{
+			name: "count is scoped",
+			run: func(tx *gorm.DB) *gorm.DB {
+				var n int64
+				return tx.WithContext(board).Model(&models.Company{}).Count(&n)
+			},
+			want: `"companies"."tenant_id" = $`,
+		},
+		{
+			name: "update is scoped",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(board).Model(&models.Job{}).Where("id = ?", 1).Update("status", 0)
+			},
+			want: `"jobs"."tenant_id" = $`,
+		},
+		{
+			name: "delete is scoped",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(board).Where("id = ?", 1).Delete(&models.JobType{})
+			},
+			want: `"job_types"."tenant_id" = $`,
+		},
+		{
+			name: "unscoped model is left alone",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(board).First(&models.Role{})
+			},
+			notWant: "tenant_id",
+		},
+		{
+			name: "system context sees every tenant",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(System(context.Background())).First(&models.User{})
+			},
+			notWant: "tenant_id",
+		},
+		{
+			name: "missing tenant fails closed",
+			run: func(tx *gorm.DB) *gorm.DB {
+				return tx.WithContext(context.Background()).First(&models.User{})
+			},
+			wantErr: ErrNoTenant,
+		},
+		{
+			name: "record of another tenant is not written back",
+			run: func(tx *gorm.DB) *gorm.DB {
+				user := models.User{TenantID: 8}
+				user.ID = 1
+				return tx.WithContext(board).Model(&user).Update("username", "jane")
+			},
+			wantErr: ErrCrossTenant,
+		},
+		{
+			name: "record of another tenant is not deleted",
+			run: func(tx *gorm.DB) *gorm.DB {
+				job := models.Job{TenantID: 8}
+				job.ID = 1
+				return tx.WithContext(board).Delete(&job)
+			},
+			wantErr: ErrCrossTenant,
+		},
+		{
+			name: "record of another tenant is not saved",
+			run: func(tx *gorm.DB) *gorm.DB {
+				company := models.Company{TenantID: 8, Name: "Other"}
+				company.ID = 1
+				return tx.WithContext(board).Save(&company)
+			},
+			wantErr: ErrCrossTenant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.run(db.Session(&gorm.Session{}))
+			if tt.wantErr != nil {
+				if !errors.Is(result.Error, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", result.Error, tt.wantErr)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+			sql := result.Statement.SQL.String()
+			if tt.want != "" && !strings.Contains(sql, tt.want) {
+				t.Errorf("%s\nwant it to contain %s", sql, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(sql, tt.notWant) {
+				t.Errorf("%s\nwant it not to contain %s", sql, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestAssignTenant(t *testing.T) {
+	db := dryRunDB(t)
+
+	company := models.Company{Name: "Acme", Email: "jobs@acme.test"}
+	if err := db.WithContext(WithTenant(context.Background(), 7)).Create(&company).Error; err != nil {
+		t.Fatal(err)
+	}
+	if company.TenantID != 7 {
+		t.Errorf("TenantID = %d, want 7", company.TenantID)
+	}
+
+	other := models.Company{TenantID: 8, Name: "Other", Email: "jobs@other.test"}
+	err := db.WithContext(WithTenant(context.Background(), 7)).Create(&other).Error
+	if !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("creating a record of another tenant: error = %v, want %v", err, ErrCrossTenant)
+	}
+}
+
+func TestAssignTenantBatch(t *testing.T) {
+	db := dryRunDB(t)
+	board := WithTenant(context.Background(), 7)
+
+	types := []models.JobType{{Name: "Full time"}, {Name: "Part time"}}
+	if err := db.WithContext(board).Create(&types).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, jobType := range types {
+		if jobType.TenantID != 7 {
+			t.Errorf("%s: TenantID = %d, want 7", jobType.Name, jobType.TenantID)
+		}
+	}
+
+	// one record of another tenant refuses the whole batch
+	mixed := []models.JobType{{Name: "Contract"}, {TenantID: 8, Name: "Internship"}}
+	if err := db.WithContext(board).Create(&mixed).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("error = %v, want %v", err, ErrCrossTenant)
+	}
+
+	if err := db.WithContext(context.Background()).Create(&models.JobType{Name: "Seasonal"}).Error; !errors.Is(err, ErrNoTenant) {
+		t.Errorf("creating without a tenant: error = %v, want %v", err, ErrNoTenant)
+	}
+}
@@ -0,0 +1,251 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// FakeDB answers the SQL of a gorm.DB from a script, so handlers can be
+// tested without Postgres. A statement is answered by the first rule whose
+// fragments it all contains; without one, inserts return a new id, other
+// queries no rows and other statements affect one row. Every statement is
+// logged with its arguments.
+type FakeDB struct {
+	mu     sync.Mutex
+	rules  []*Rule
+	log    []statement
+	lastID int64
+}
+
+type statement struct {
+	query string
+	args  []driver.Value
+}
+
+// Rule is the scripted answer to matching statements
+type Rule struct {
+	fragments []string
+	columns   []string
+	rows      [][]driver.Value
+	affected  int64
+	err       error
+	times     int // answers left, 0 for unlimited
+}
+
+// On adds a rule for statements containing every fragment
+func (f *FakeDB) On(fragments ...string) *Rule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rule := &Rule{fragments: fragments, affected: 1}
+	f.rules = append(f.rules, rule)
+	return rule
+}
+
+// Rows makes the rule return rows, each with a value per column
+func (r *Rule) Rows(columns []string, rows ...[]driver.Value) *Rule {
+	r.columns, r.rows = columns, rows
+	return r
+}
+
+// Affected sets the rows a statement reports as changed
+func (r *Rule) Affected(n int64) *Rule {
+	r.affected = n
+	return r
+}
+
+// Fail makes matching statements return err, e.g. a unique violation
+func (r *Rule) Fail(err error) *Rule {
+	r.err = err
+	return r
+}
+
+// Once lets the rule answer a single statement
+func (r *Rule) Once() *Rule {
+	r.times = 1
+	return r
+}
+
+// Ran reports whether a statement containing every fragment was executed
+func (f *FakeDB) Ran(fragments ...string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, statement := range f.log {
+		if containsAll(statement.query, fragments) {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns the arguments of the first statement containing every
+// fragment, nil when none ran
+func (f *FakeDB) Args(fragments ...string) []driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, statement := range f.log {
+		if containsAll(statement.query, fragments) {
+			return statement.args
+		}
+	}
+	return nil
+}
+
+// Log returns the executed statements in order
+func (f *FakeDB) Log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queries := make([]string, len(f.log))
+	for i, statement := range f.log {
+		queries[i] = statement.query
+	}
+	return queries
+}
+
+func containsAll(statement string, fragments []string) bool {
+	for _, fragment := range fragments {
+		if !strings.Contains(statement, fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// answer logs the statement and finds its rule
+func (f *FakeDB) answer(query string, args []driver.NamedValue) *Rule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.log = append(f.log, statement{query: query, args: values})
+	for i, rule := range f.rules {
+		if !containsAll(query, rule.fragments) {
+			continue
+		}
+		if rule.times == 1 {
+			f.rules = append(f.rules[:i:i], f.rules[i+1:]...)
+		}
+		return rule
+	}
+	if strings.HasPrefix(query, "INSERT") && strings.Contains(query, `RETURNING "id"`) {
+		f.lastID++
+		return &Rule{columns: []string{"id"}, rows: [][]driver.Value{{f.lastID}}, affected: 1}
+	}
+	return &Rule{affected: 1}
+}
+
+var fakeDBs sync.Map // DSN -> *FakeDB
+var fakeDBCount atomic.Int64
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// OpenFakeDB returns a gorm.DB backed by a new FakeDB
+func OpenFakeDB() (*gorm.DB, *FakeDB, error) {
+	fake := &FakeDB{}
+	dsn := fmt.Sprintf("fakedb-%d", fakeDBCount.Add(1))
+	fakeDBs.Store(dsn, fake)
+
+	conn, err := sql.Open("fakedb", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, fake, nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fake, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: unknown database %q", dsn)
+	}
+	return &fakeConn{db: fake.(*FakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *FakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.answer("COMMIT", nil)
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.answer("ROLLBACK", nil)
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rule := c.db.answer(query, args)
+	if rule.err != nil {
+		return nil, rule.err
+	}
+	return driver.RowsAffected(rule.affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rule := c.db.answer(query, args)
+	if rule.err != nil {
+		return nil, rule.err
+	}
+	return &fakeRows{columns: rule.columns, rows: rule.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -1,7 +1,7 @@
 package test
 
 import (
-	"Auth/firebase"
+	"Auth/identity"
 	"context"
 	"strings"
 
@@ -16,8 +16,7 @@ func TestClaims(c *fiber.Ctx) error {
 
 	tokenStr := strings.Replace(authHeader, "Bearer ", "", 1)
 
-	authClient := firebase.GetAuthClient()
-	token, err := authClient.VerifyIDToken(context.Background(), tokenStr)
+	token, err := identity.Get().VerifyIDToken(context.Background(), tokenStr)
 	if err != nil {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
 	}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Action token purposes
+const (
+	PurposeMFA         = "mfa"          // login challenge, exchanged with a TOTP or recovery code
+	PurposeEmailVerify = "email_verify" // link sent to prove ownership of the email address
+	PurposeInvitation  = "invitation"   // link sent to join a company's team
+	PurposeMagicLink   = "magic_link"   // passwordless sign-in link
+)
+
+// ActionClaims are short lived, single purpose tokens (MFA challenge, email
+// links, ...). The audience is bound to the purpose so an action token can
+// never be accepted as an access token and vice versa.
+type ActionClaims struct {
+	Purpose string            `json:"purpose"`
+	UserID  uint              `json:"user_id"`
+	Email   string            `json:"email,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateActionToken signs an action token with the same keys as access tokens
+func GenerateActionToken(purpose string, userID uint, email string, ttl time.Duration, data map[string]string) (string, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := ActionClaims{
+		Purpose: purpose,
+		UserID:  userID,
+		Email:   email,
+		Data:    data,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    config.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{config.Issuer + ":" + purpose},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	return signClaims(claims)
+}
+
+// ValidateActionToken parses an action token and checks it was issued for purpose
+func ValidateActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, verificationKey,
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.Issuer+":"+purpose),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return nil, ErrInvalidClaims
+	}
+
+	return claims, nil
+}
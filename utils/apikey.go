@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// API keys look like ak_<8 hex id>_<secret>. The "ak_<id>" prefix is stored
+// in clear to find the key and to show it in lists; the secret part is only
+// ever returned once and persisted as a hash (HashToken of the whole key).
+const (
+	apiKeyTag       = "ak_"
+	apiKeyIDLen     = 8
+	apiKeyPrefixLen = len(apiKeyTag) + apiKeyIDLen
+)
+
+// API key scopes; human users (JWT, Firebase) are not limited by scopes
+const (
+	ScopeJobsRead       = "jobs:read"
+	ScopeJobsWrite      = "jobs:write"
+	ScopeCompaniesRead  = "companies:read"
+	ScopeCompaniesWrite = "companies:write"
+)
+
+// APIKeyScopes lists every scope a key can be granted
+var APIKeyScopes = []string{ScopeJobsRead, ScopeJobsWrite, ScopeCompaniesRead, ScopeCompaniesWrite}
+
+// ValidAPIKeyScope reports whether scope is a known API key scope
+func ValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateAPIKey returns a new key and its public prefix
+func GenerateAPIKey() (key, prefix string, err error) {
+	id := make([]byte, apiKeyIDLen/2)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = apiKeyTag + hex.EncodeToString(id)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// APIKeyPrefix extracts the public prefix of a presented key
+func APIKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyTag) || len(key) <= apiKeyPrefixLen+1 || key[apiKeyPrefixLen] != '_' {
+		return "", false
+	}
+	return key[:apiKeyPrefixLen], true
+}
@@ -27,14 +27,20 @@ type JWTClaims struct {
 	Email       string `json:"email"`
 	FirebaseUID string `json:"firebase_uid"`
 	Role        string `json:"role"`
+	SessionID   uint   `json:"sid,omitempty"` // refresh token family the token was issued for
+	TenantID    uint   `json:"tid,omitempty"` // job board the user belongs to (see tenancy)
+	Actor       *Actor `json:"act,omitempty"` // set on impersonation tokens only
 	jwt.RegisteredClaims
 }
 
 // JWTConfig holds JWT configuration (cached for performance)
 type JWTConfig struct {
-	Secret      string
-	ExpiryHours int
-	Issuer      string
+	Secret             string
+	ExpiryHours        int
+	RefreshExpiryHours int
+	Issuer             string
+	KeysDir            string // asymmetric signing keys, see keyring.go
+	ActiveKID          string
 }
 
 // TokenWithExpiry holds token and its expiration info
@@ -42,6 +48,7 @@ type TokenWithExpiry struct {
 	Token     string
 	ExpiresIn int64 // seconds until expiration
 	ExpiresAt time.Time
+	JTI       string `json:"-"` // token id, set for tokens that can be denylisted
 }
 
 var (
@@ -53,16 +60,22 @@ var (
 // loadJWTConfig loads and validates JWT configuration from environment
 func loadJWTConfig() (*JWTConfig, error) {
 	configOnce.Do(func() {
+		// With a keys directory tokens are signed asymmetrically and
+		// the shared secret is no longer needed
+		keysDir := os.Getenv("JWT_KEYS_DIR")
+
 		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			configLoadErr = ErrMissingJWTSecret
-			return
-		}
+		if keysDir == "" {
+			if secret == "" {
+				configLoadErr = ErrMissingJWTSecret
+				return
+			}
 
-		// Validate secret strength (critical for HS256)
-		if len(secret) < 32 {
-			configLoadErr = ErrWeakJWTSecret
-			return
+			// Validate secret strength (critical for HS256)
+			if len(secret) < 32 {
+				configLoadErr = ErrWeakJWTSecret
+				return
+			}
 		}
 
 		expiryHours, err := strconv.Atoi(os.Getenv("JWT_EXPIRY"))
@@ -70,15 +83,23 @@ func loadJWTConfig() (*JWTConfig, error) {
 			expiryHours = 24 // default to 24 hours
 		}
 
+		refreshExpiryHours, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_EXPIRY"))
+		if err != nil || refreshExpiryHours <= 0 {
+			refreshExpiryHours = 720 // default to 30 days
+		}
+
 		issuer := os.Getenv("JWT_ISSUER")
 		if issuer == "" {
 			issuer = "my-app" // default issuer
 		}
 
 		jwtConfig = &JWTConfig{
-			Secret:      secret,
-			ExpiryHours: expiryHours,
-			Issuer:      issuer,
+			Secret:             secret,
+			ExpiryHours:        expiryHours,
+			RefreshExpiryHours: refreshExpiryHours,
+			Issuer:             issuer,
+			KeysDir:            keysDir,
+			ActiveKID:          os.Getenv("JWT_ACTIVE_KID"),
 		}
 	})
 
@@ -86,12 +107,23 @@ func loadJWTConfig() (*JWTConfig, error) {
 }
 
 // GenerateJWT creates and signs a JWT token string with user info
-func GenerateJWT(userID uint, email, firebaseUID, role string) (string, error) {
+func GenerateJWT(userID uint, email, firebaseUID, role string, tenantID uint) (string, error) {
+	return generateJWT(userID, email, firebaseUID, role, 0, tenantID)
+}
+
+// generateJWT builds the access token claims, optionally bound to a session
+func generateJWT(userID uint, email, firebaseUID, role string, sessionID, tenantID uint) (string, error) {
 	config, err := loadJWTConfig()
 	if err != nil {
 		return "", err
 	}
 
+	// jti lets a single token be revoked before it expires
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 	expiresAt := now.Add(time.Hour * time.Duration(config.ExpiryHours))
 
@@ -100,7 +132,10 @@ func GenerateJWT(userID uint, email, firebaseUID, role string) (string, error) {
 		Email:       email,
 		FirebaseUID: firebaseUID,
 		Role:        role,
+		SessionID:   sessionID,
+		TenantID:    tenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			Issuer:    config.Issuer,
 			Subject:   strconv.FormatUint(uint64(userID), 10),
 			Audience:  jwt.ClaimStrings{config.Issuer},
@@ -110,8 +145,7 @@ func GenerateJWT(userID uint, email, firebaseUID, role string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.Secret))
+	return signClaims(claims)
 }
 
 // ValidateJWT parses and validates a JWT token string
@@ -121,13 +155,11 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 		return nil, err
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method to prevent algorithm confusion attacks
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(config.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, verificationKey,
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.Issuer),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		// Distinguish between different error types for better UX
@@ -155,13 +187,19 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 
 // GenerateJWTWithExpiry creates a JWT and returns token with expiry info
 // This is a convenience function that returns additional expiration metadata
-func GenerateJWTWithExpiry(userID uint, email, firebaseUID, role string) (*TokenWithExpiry, error) {
+func GenerateJWTWithExpiry(userID uint, email, firebaseUID, role string, tenantID uint) (*TokenWithExpiry, error) {
+	return GenerateSessionJWT(userID, email, firebaseUID, role, 0, tenantID)
+}
+
+// GenerateSessionJWT creates a JWT bound to a refresh token family (sid claim)
+// and returns token with expiry info
+func GenerateSessionJWT(userID uint, email, firebaseUID, role string, sessionID, tenantID uint) (*TokenWithExpiry, error) {
 	config, err := loadJWTConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := GenerateJWT(userID, email, firebaseUID, role)
+	token, err := generateJWT(userID, email, firebaseUID, role, sessionID, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -176,32 +214,6 @@ func GenerateJWTWithExpiry(userID uint, email, firebaseUID, role string) (*Token
 	}, nil
 }
 
-// RefreshJWT generates a new token from an existing valid or expired token
-func RefreshJWT(tokenString string) (string, error) {
-	claims, err := ValidateJWT(tokenString)
-	if err != nil {
-		// Allow refresh even if token is expired
-		if !errors.Is(err, ErrExpiredToken) {
-			return "", err
-		}
-		// Parse without validation to get claims from expired token
-		token, parseErr := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			config, configErr := loadJWTConfig()
-			if configErr != nil {
-				return nil, configErr
-			}
-			return []byte(config.Secret), nil
-		})
-		if parseErr != nil {
-			return "", fmt.Errorf("%w: cannot refresh", ErrInvalidToken)
-		}
-		claims, _ = token.Claims.(*JWTClaims)
-	}
-
-	// Generate new token with same user info
-	return GenerateJWT(claims.UserID, claims.Email, claims.FirebaseUID, claims.Role)
-}
-
 // GetUserIDFromToken extracts user ID from a validated token
 func GetUserIDFromToken(tokenString string) (uint, error) {
 	claims, err := ValidateJWT(tokenString)
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keyring errors
+var (
+	ErrNoSigningKey   = errors.New("JWT_ACTIVE_KID must name one private key in JWT_KEYS_DIR")
+	ErrUnknownKeyID   = errors.New("token signed with unknown key id")
+	ErrUnsupportedKey = errors.New("unsupported key type, use RSA, ECDSA (P-256/384/521) or Ed25519")
+)
+
+// signingKey is one entry of the keyring. Retired keys only carry the
+// public half and are kept around to verify tokens issued before rotation.
+type signingKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer // nil for verification-only keys
+	Public  crypto.PublicKey
+}
+
+// Keyring holds the active signing key and every key still accepted for verification
+type Keyring struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// JWK is a public key in RFC 7517 format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the body of /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	keyring        *Keyring
+	keyringOnce    sync.Once
+	keyringLoadErr error
+)
+
+// loadKeyring reads every <kid>.pem file in JWT_KEYS_DIR. Returns nil when
+// no directory is configured, in which case tokens are signed with HS256.
+func loadKeyring() (*Keyring, error) {
+	keyringOnce.Do(func() {
+		config, err := loadJWTConfig()
+		if err != nil {
+			keyringLoadErr = err
+			return
+		}
+		if config.KeysDir == "" {
+			return
+		}
+
+		files, err := filepath.Glob(filepath.Join(config.KeysDir, "*.pem"))
+		if err != nil {
+			keyringLoadErr = err
+			return
+		}
+
+		ring := &Keyring{keys: map[string]*signingKey{}}
+		for _, file := range files {
+			kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+			key, err := parseKeyFile(kid, file)
+			if err != nil {
+				keyringLoadErr = fmt.Errorf("load key %s: %w", file, err)
+				return
+			}
+			ring.keys[kid] = key
+		}
+
+		activeKID := config.ActiveKID
+		if activeKID == "" {
+			// a single private key needs no explicit selection
+			for kid, key := range ring.keys {
+				if key.Private != nil {
+					if activeKID != "" {
+						keyringLoadErr = ErrNoSigningKey
+						return
+					}
+					activeKID = kid
+				}
+			}
+		}
+
+		active, ok := ring.keys[activeKID]
+		if !ok || active.Private == nil {
+			keyringLoadErr = ErrNoSigningKey
+			return
+		}
+		ring.active = active
+		keyring = ring
+	})
+
+	return keyring, keyringLoadErr
+}
+
+// parseKeyFile accepts PKCS#8, PKCS#1 and SEC 1 private keys or PKIX public keys
+func parseKeyFile(kid, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{KID: kid}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.Private = signer
+		key.Public = signer.Public()
+	} else {
+		key.Public = parsed
+	}
+
+	// The algorithm follows from the key type, so rotating to a new
+	// algorithm is just adding a key file of that type.
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, ErrUnsupportedKey
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	return key, nil
+}
+
+// signClaims signs claims with the active key, or with JWT_SECRET (HS256)
+// when no keyring is configured
+func signClaims(claims jwt.Claims) (string, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return "", err
+	}
+	ring, err := loadKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	if ring == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.Secret))
+	}
+
+	token := jwt.NewWithClaims(ring.active.Method, claims)
+	token.Header["kid"] = ring.active.KID
+	return token.SignedString(ring.active.Private)
+}
+
+// verificationKey is the jwt.Keyfunc for every token we issue
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return nil, err
+	}
+	ring, err := loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	if ring == nil {
+		// Verify signing method to prevent algorithm confusion attacks
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(config.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ring.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	// The key decides the algorithm, never the token header
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// GetJWKS returns the public verification keys. Empty when signing with HS256.
+func GetJWKS() (*JWKSet, error) {
+	ring, err := loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &JWKSet{Keys: []JWK{}}
+	if ring == nil {
+		return set, nil
+	}
+
+	kids := make([]string, 0, len(ring.keys))
+	for kid := range ring.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := ring.keys[kid]
+		jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// SigningAlgorithm names the algorithm new tokens are signed with
+func SigningAlgorithm() (string, error) {
+	ring, err := loadKeyring()
+	if err != nil {
+		return "", err
+	}
+	if ring == nil {
+		return jwt.SigningMethodHS256.Alg(), nil
+	}
+	return ring.active.Method.Alg(), nil
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useKeyring points the JWT configuration at dir and reloads it
+func useKeyring(t *testing.T, dir, activeKID string) {
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_ACTIVE_KID", activeKID)
+	reset := func() {
+		jwtConfig, configOnce, configLoadErr = nil, sync.Once{}, nil
+		keyring, keyringOnce, keyringLoadErr = nil, sync.Once{}, nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// writePEM stores a key as <kid>.pem
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyringSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	active, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "current", "EC PRIVATE KEY", der)
+
+	// the retired key only keeps its public half
+	retiredPublic, retired, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.MarshalPKIXPublicKey(retiredPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "retired", "PUBLIC KEY", der)
+
+	useKeyring(t, dir, "")
+
+	claims := jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	signed, err := signClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromRetired := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	fromRetired.Header["kid"] = "retired"
+	retiredToken, err := fromRetired.SignedString(retired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	unknown.Header["kid"] = "missing"
+	unknownToken, err := unknown.SignedString(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the key decides the algorithm: HS256 with the public key as secret
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = "current"
+	confusedToken, err := confused.SignedString(elliptic.MarshalCompressed(elliptic.P256(), active.X, active.Y))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"active key", signed, nil},
+		{"retired key", retiredToken, nil},
+		{"unknown key id", unknownToken, ErrUnknownKeyID},
+		{"algorithm confusion", confusedToken, jwt.ErrTokenUnverifiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.Parse(tt.token, verificationKey)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("token rejected: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	header, _, err := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := header.Header["kid"]; kid != "current" {
+		t.Errorf("kid = %v, want current", kid)
+	}
+}
+
+func TestKeyringRequiresActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"a", "b"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePEM(t, dir, kid, "EC PRIVATE KEY", der)
+	}
+
+	tests := []struct {
+		name      string
+		activeKID string
+		wantErr   error
+	}{
+		{"two private keys, none selected", "", ErrNoSigningKey},
+		{"selected key missing", "c", ErrNoSigningKey},
+		{"selected", "b", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeyring(t, dir, tt.activeKID)
+			if _, err := loadKeyring(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("loadKeyring() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	dir := t.TempDir()
+
+	ec, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2024", "PRIVATE KEY", der)
+
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2023", "PUBLIC KEY", der)
+
+	useKeyring(t, dir, "2024")
+
+	set, err := GetJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []JWK{
+		{Kty: "OKP", Kid: "2023", Use: "sig", Alg: "EdDSA", Crv: "Ed25519"},
+		{Kty: "EC", Kid: "2024", Use: "sig", Alg: "ES384", Crv: "P-384"},
+	}
+	if len(set.Keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(set.Keys), len(want))
+	}
+	for i, key := range set.Keys {
+		if key.Kty != want[i].Kty || key.Kid != want[i].Kid || key.Use != want[i].Use ||
+			key.Alg != want[i].Alg || key.Crv != want[i].Crv || key.X == "" {
+			t.Errorf("key %d = %+v, want %+v", i, key, want[i])
+		}
+	}
+	if x := set.Keys[1]; len(x.X) != 64 || len(x.Y) != 64 {
+		t.Errorf("P-384 coordinates are %d and %d characters, want 64", len(x.X), len(x.Y))
+	}
+}
+
+func TestGetJWKSWithoutKeyring(t *testing.T) {
+	useKeyring(t, "", "")
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+
+	set, err := GetJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 0 {
+		t.Errorf("got %d keys for HS256, want none", len(set.Keys))
+	}
+}
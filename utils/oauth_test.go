@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"S256", verifier, challenge, true},
+		{"other verifier", strings.Replace(verifier, "d", "e", 1), challenge, false},
+		{"plain method", verifier, verifier, false},
+		{"challenge sent as verifier", challenge, challenge, false},
+		{"too short", verifier[:42], challenge, false},
+		{"too long", strings.Repeat("a", 129), challenge, false},
+		{"reserved characters", verifier[:42] + "+", challenge, false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("VerifyPKCE(%q, %q) = %v, want %v", tt.verifier, tt.challenge, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOAuthAccessToken(t *testing.T) {
+	useKeyring(t, "", "")
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name        string
+		userID      uint
+		wantSubject string
+	}{
+		{"user grant", 42, "42"},
+		{"client credentials", 0, "oc_client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := GenerateOAuthAccessToken("oc_client", tt.userID, "openid profile")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ValidateOAuthAccessToken(access.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.ID == "" || claims.ID != access.JTI {
+				t.Errorf("jti = %q, want the denylist id %q", claims.ID, access.JTI)
+			}
+			if claims.Subject != tt.wantSubject || claims.UserID != tt.userID || claims.Scope != "openid profile" {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			// the token id is for the denylist, never for clients
+			body, err := json.Marshal(access)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(body), access.JTI) {
+				t.Errorf("serialized token leaks its jti: %s", body)
+			}
+		})
+	}
+
+	// tokens for another audience, such as our own API's, are refused
+	own, err := signClaims(jwt.RegisteredClaims{
+		Subject:   "42",
+		Issuer:    "my-app",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateOAuthAccessToken(own); err == nil {
+		t.Error("a token without the OAuth audience was accepted")
+	}
+}
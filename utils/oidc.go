@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OpenID Connect scopes. openid makes the token endpoint return an ID
+// token, profile and email select the claims it and /userinfo carry.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OIDCScopes are about the signed-in user and never granted to a client
+// acting for itself (client credentials)
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OIDCProfile is what the ID token and /userinfo may tell about a user
+type OIDCProfile struct {
+	UserID        uint
+	Email         string
+	EmailVerified bool
+	Name          string // given name, User_Details.Name
+	FamilyName    string // User_Details.Lastname
+	Username      string
+	UpdatedAt     time.Time
+}
+
+// Claims returns the standard OIDC claims for the granted scopes; sub is
+// the user ID, the same subject as in our access tokens
+func (p OIDCProfile) Claims(scope string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": strconv.FormatUint(uint64(p.UserID), 10),
+	}
+	scopes := strings.Fields(scope)
+	for _, s := range scopes {
+		switch s {
+		case ScopeEmail:
+			claims["email"] = p.Email
+			claims["email_verified"] = p.EmailVerified
+		case ScopeProfile:
+			if name := strings.TrimSpace(p.Name + " " + p.FamilyName); name != "" {
+				claims["name"] = name
+			}
+			if p.Name != "" {
+				claims["given_name"] = p.Name
+			}
+			if p.FamilyName != "" {
+				claims["family_name"] = p.FamilyName
+			}
+			claims["preferred_username"] = p.Username
+			if !p.UpdatedAt.IsZero() {
+				claims["updated_at"] = p.UpdatedAt.Unix()
+			}
+		}
+	}
+	return claims
+}
+
+// Issuer is the iss of every token we sign. For OpenID Connect JWT_ISSUER
+// must be the public https URL of this service.
+func Issuer() (string, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.Issuer, nil
+}
+
+// GenerateIDToken signs an OpenID Connect ID token for clientID (the
+// audience) with the same keys as our access tokens. nonce is echoed from
+// the authorization request, empty on refresh.
+func GenerateIDToken(clientID string, profile OIDCProfile, scope, nonce string) (string, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for key, value := range profile.Claims(scope) {
+		claims[key] = value
+	}
+	claims["iss"] = config.Issuer
+	claims["aud"] = clientID
+	claims["azp"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(OAuthAccessTokenTTL()).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	return signClaims(claims)
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordConfig selects the algorithm and cost for new password hashes
+type PasswordConfig struct {
+	Algorithm     string // bcrypt or argon2id
+	BcryptCost    int
+	ArgonMemory   uint32 // KiB
+	ArgonTime     uint32
+	ArgonThreads  uint8
+	ArgonKeyLen   uint32
+	ArgonSaltSize int
+}
+
+var (
+	passwordConfig     *PasswordConfig
+	passwordConfigErr  error
+	passwordConfigOnce sync.Once
+)
+
+// loadPasswordConfig reads PASSWORD_HASH_ALGO, BCRYPT_COST and ARGON2_* from
+// environment. Argon2 settings out of range are an error rather than being
+// truncated (ARGON2_THREADS=256 would become 0, on which argon2 panics).
+func loadPasswordConfig() (*PasswordConfig, error) {
+	passwordConfigOnce.Do(func() {
+		algorithm := os.Getenv("PASSWORD_HASH_ALGO")
+		if algorithm != "argon2id" {
+			algorithm = "bcrypt" // default
+		}
+
+		bcryptCost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+		if err != nil || bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			bcryptCost = 12
+		}
+
+		memory, err := envIntRange("ARGON2_MEMORY", 64*1024, 8, math.MaxUint32)
+		if err != nil {
+			passwordConfigErr = err
+			return
+		}
+		iterations, err := envIntRange("ARGON2_TIME", 3, 1, math.MaxUint32)
+		if err != nil {
+			passwordConfigErr = err
+			return
+		}
+		threads, err := envIntRange("ARGON2_THREADS", 2, 1, math.MaxUint8)
+		if err != nil {
+			passwordConfigErr = err
+			return
+		}
+
+		passwordConfig = &PasswordConfig{
+			Algorithm:     algorithm,
+			BcryptCost:    bcryptCost,
+			ArgonMemory:   uint32(memory),
+			ArgonTime:     uint32(iterations),
+			ArgonThreads:  uint8(threads),
+			ArgonKeyLen:   32,
+			ArgonSaltSize: 16,
+		}
+	})
+
+	return passwordConfig, passwordConfigErr
+}
+
+// CheckPasswordConfig validates the password hashing settings. main calls
+// it at startup, so a bad value stops the service instead of failing logins.
+func CheckPasswordConfig() error {
+	_, err := loadPasswordConfig()
+	return err
+}
+
+// envIntRange reads an integer in [min, max] from environment, or returns
+// the fallback when the variable is unset
+func envIntRange(key string, fallback, min, max int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("%s must be an integer from %d to %d", key, min, max)
+	}
+	return value, nil
+}
+
+// HashPassword hashes a password with the configured algorithm
+func HashPassword(password string) (string, error) {
+	config, err := loadPasswordConfig()
+	if err != nil {
+		return "", err
+	}
+
+	if config.Algorithm == "argon2id" {
+		salt := make([]byte, config.ArgonSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, config.ArgonTime, config.ArgonMemory, config.ArgonThreads, config.ArgonKeyLen)
+
+		// PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, config.ArgonMemory, config.ArgonTime, config.ArgonThreads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost)
+	return string(hash), err
+}
+
+// VerifyPassword checks a password against a stored hash. needsRehash reports
+// that the hash was made with another algorithm or cost than configured, so
+// the caller should store a fresh HashPassword result after a successful login.
+func VerifyPassword(encoded, password string) (ok bool, needsRehash bool, err error) {
+	config, err := loadPasswordConfig()
+	if err != nil {
+		return false, false, err
+	}
+
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		var version int
+		var memory, iterations uint32
+		var threads uint8
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 6 {
+			return false, false, ErrUnknownPasswordHash
+		}
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return false, false, ErrUnknownPasswordHash
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil ||
+			iterations < 1 || threads < 1 {
+			return false, false, ErrUnknownPasswordHash
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, false, ErrUnknownPasswordHash
+		}
+		key, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil {
+			return false, false, ErrUnknownPasswordHash
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, computed) != 1 {
+			return false, false, nil
+		}
+
+		needsRehash = config.Algorithm != "argon2id" ||
+			memory != config.ArgonMemory || iterations != config.ArgonTime || threads != config.ArgonThreads
+		return true, needsRehash, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return false, false, ErrUnknownPasswordHash
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	needsRehash = config.Algorithm != "bcrypt" || err != nil || cost != config.BcryptCost
+	return true, needsRehash, nil
+}
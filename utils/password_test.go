@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEnvIntRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"unset uses fallback", "", 4, false},
+		{"in range", "2", 2, false},
+		{"lower bound", "1", 1, false},
+		{"upper bound", "255", 255, false},
+		{"below range", "0", 0, true},
+		{"above range", "256", 0, true},
+		{"would wrap as uint8", "257", 0, true},
+		{"not a number", "four", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_ENV_INT_RANGE", tt.value)
+			got, err := envIntRange("TEST_ENV_INT_RANGE", 4, 1, math.MaxUint8)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("envIntRange(%q) = %d, %v, want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
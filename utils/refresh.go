@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// GenerateOpaqueToken returns a URL-safe random token with 256 bits of entropy
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest of an opaque token.
+// Only this digest is persisted, so a database leak does not leak usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRefreshToken creates a new opaque refresh token with expiry info
+func GenerateRefreshToken() (*TokenWithExpiry, error) {
+	config, err := loadJWTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Hour * time.Duration(config.RefreshExpiryHours)
+	return &TokenWithExpiry{
+		Token:     token,
+		ExpiresIn: int64(ttl.Seconds()),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
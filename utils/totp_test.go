@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"as printed", "abcde-fghij", "abcdefghij"},
+		{"without dash", "abcdefghij", "abcdefghij"},
+		{"capitals and spaces", "  ABCDE FGHIJ ", "abcdefghij"},
+		{"several dashes", "ab-cde-fg-hij", "abcdefghij"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+				t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodeAsTyped(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := codes[0]
+	stored := HashToken(NormalizeRecoveryCode(printed))
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"as printed", printed},
+		{"without dash", printed[:5] + printed[6:]},
+		{"capitals and spaces", " " + strings.ToUpper(printed[:5]) + " " + printed[6:] + " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if HashToken(NormalizeRecoveryCode(tt.input)) != stored {
+				t.Errorf("%q does not match the stored hash of %q", tt.input, printed)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	step := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		c, err := totpCode(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), step, true},
+		{"previous step", code(step - 1), step - 1, true},
+		{"next step", code(step + 1), step + 1, true},
+		{"too old", code(step - totpSkew - 1), 0, false},
+		{"wrong length", "12345", 0, false},
+		{"empty", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	ErrNoAppURL = errors.New("APP_URL must be the absolute http(s) URL of the frontend")
+	ErrNoAPIURL = errors.New("API_URL must be the absolute http(s) URL of this service")
+)
+
+// publicURL reads an absolute http(s) URL from the environment, without
+// the trailing slash; "" when it is unset or not such a URL
+func publicURL(name string) string {
+	value := strings.TrimRight(os.Getenv(name), "/")
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return value
+}
+
+// AppURL is the frontend (APP_URL). Emailed links that need a page, such as
+// password reset and invitations, open it.
+func AppURL() (string, error) {
+	if appURL := publicURL("APP_URL"); appURL != "" {
+		return appURL, nil
+	}
+	return "", ErrNoAppURL
+}
+
+// APIURL is where this service is publicly reachable (API_URL). Links are
+// never built from the Host of a request, which the client controls.
+func APIURL() (string, error) {
+	if apiURL := publicURL("API_URL"); apiURL != "" {
+		return apiURL, nil
+	}
+	return "", ErrNoAPIURL
+}
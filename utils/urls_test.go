@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAppURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"https", "https://jobs.example.com", "https://jobs.example.com", nil},
+		{"trailing slash", "https://jobs.example.com/", "https://jobs.example.com", nil},
+		{"with path", "http://localhost:5173/app/", "http://localhost:5173/app", nil},
+		{"unset", "", "", ErrNoAppURL},
+		{"no scheme", "jobs.example.com", "", ErrNoAppURL},
+		{"other scheme", "javascript://jobs.example.com", "", ErrNoAppURL},
+		{"no host", "https://", "", ErrNoAppURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_URL", tt.value)
+			got, err := AppURL()
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("AppURL() with APP_URL=%q = %q, %v, want %q, %v", tt.value, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	t.Setenv("API_URL", "")
+	if _, err := APIURL(); !errors.Is(err, ErrNoAPIURL) {
+		t.Errorf("APIURL() without API_URL = %v, want %v", err, ErrNoAPIURL)
+	}
+
+	t.Setenv("API_URL", "https://api.example.com/")
+	if got, err := APIURL(); err != nil || got != "https://api.example.com" {
+		t.Errorf("APIURL() = %q, %v, want %q", got, err, "https://api.example.com")
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// iosToken matches "ios" as a word ("MyApp/2.1 (iOS 17.4)"), not inside
+// other tokens such as Chrome's "CriOS/"
+var iosToken = regexp.MustCompile(`(^|[^a-z])ios([^a-z]|$)`)
+
+// DeviceName turns a User-Agent into a short label such as "Chrome on macOS".
+// It only needs to be good enough for a user to recognise their sessions.
+func DeviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp"), strings.Contains(ua, "dart:io"),
+		strings.Contains(ua, "cfnetwork"), strings.Contains(ua, "go-http-client"):
+		browser = "App"
+	case strings.Contains(ua, "curl/"), strings.Contains(ua, "postman"):
+		browser = "API client"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"),
+		strings.Contains(ua, "cpu os "), iosToken.MatchString(ua):
+		platform = "iOS"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"), strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "cros"):
+		platform = "ChromeOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
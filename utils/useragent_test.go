@@ -0,0 +1,32 @@
+package utils
+
+import "testing"
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"empty", "", "Unknown device"},
+		{"chrome on macos", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36", "Chrome on macOS"},
+		{"edge on windows", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0", "Edge on Windows"},
+		{"firefox on linux", "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", "Firefox on Linux"},
+		{"safari on iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"chrome on ipad", "Mozilla/5.0 (iPad; CPU OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0.6478.54 Mobile/15E148 Safari/604.1", "Chrome on iOS"},
+		{"chrome on android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"criOS token alone is not ios", "CriOS/126.0 (X11; Linux x86_64)", "Chrome on Linux"},
+		{"native ios app", "JobBoard/2.1 (iOS 17.4; Scale/3.00)", "Unknown browser on iOS"},
+		{"bios in a token is not ios", "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0; bios) Chrome/126.0.0.0", "Chrome on ChromeOS"},
+		{"curl", "curl/8.5.0", "API client"},
+		{"okhttp", "okhttp/4.12.0", "App"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeviceName(tt.userAgent); got != tt.want {
+				t.Errorf("DeviceName(%q) = %q, want %q", tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}